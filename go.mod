@@ -1,3 +1,5 @@
 module github.com/arturoeanton/go-xml
 
 go 1.25.5
+
+require golang.org/x/crypto v0.48.0
@@ -22,16 +22,24 @@ func main() {
 		xml.CliFormat(args)
 	case "json":
 		xml.CliToJson(args)
+	case "yaml":
+		xml.CliToYaml(args)
+	case "json2xml":
+		xml.CliJsonToXml(args)
 	case "csv":
 		xml.CliToCsv(args)
 	case "query":
 		xml.CliQuery(args)
+	case "validate":
+		xml.CliValidate(args)
 	case "soap":
 		xml.CliSoap(args)
 	case "call":
 		xml.CliSoapQuick(args)
 	case "wsdl":
 		xml.CliWSDL(args)
+	case "c14n":
+		xml.CliCanonicalize(args)
 	case "demo":
 		target := "all"
 		if len(args) > 0 {
@@ -51,14 +59,19 @@ func printHelp() {
 	fmt.Println("\nCommands:")
 	fmt.Println("  fmt   <file>          : Format/Beautify XML (Pretty Print)")
 	fmt.Println("  json  <file>          : Convert XML to JSON")
-	fmt.Println("  csv   <file> --path=X : Convert XML list to CSV (Flatten)")
+	fmt.Println("  yaml  <file>          : Convert XML to YAML")
+	fmt.Println("  json2xml <file> [--root=X] : Convert JSON to XML")
+	fmt.Println("  csv   <file> --path=X [--delim=tab] : Convert XML list to CSV/TSV (Flatten)")
+	fmt.Println("        records are streamed: only elements at the full path X, columns from the first one")
 	fmt.Println("  query <file> <xpath>  : Run an XPath query")
+	fmt.Println("  validate <file> --rules=rules.json : Check XML against validation rules")
 	fmt.Println("  soap  <config.json>   : Execute a SOAP request from a JSON definition")
 	fmt.Println("  call  [flags]         : Execute a quick SOAP request with parameters")
 	fmt.Println("        --url=... --action=... --ns=... --auth=wsse --user=... --pass=...")
 	fmt.Println("        --data=\"Key=Val\" --data=\"Nested/Key=Val\"")
 	fmt.Println("        --wsdl=service.wsdl : use the WSDL to validate --action and set url/ns/soapAction")
 	fmt.Println("  wsdl  <file.wsdl>     : List SOAP operations discovered in a WSDL")
+	fmt.Println("  c14n  <file>          : Canonicalize XML (Exclusive C14N) [--with-comments]")
 	fmt.Println("  demo                  : Run built-in demos")
 	fmt.Println("  demo [name]           : Run a specific demo")
 
@@ -20,10 +20,11 @@ import (
 // / CreateXadesSignature compute a signature over a detached SignedInfo node
 // and have it still validate once embedded anywhere in the final document.
 //
+// Namespace prefixes referenced only inside attribute VALUES / QName content
+// (xsi:type="cbc:AmountType"), which the algorithm can't detect, are handled
+// by the "InclusiveNamespaces PrefixList" escape hatch: WithInclusiveNamespaces.
+//
 // Known, deliberate limitations (documented rather than silently wrong):
-//   - No "InclusiveNamespaces PrefixList" support (the escape hatch for
-//     namespace prefixes referenced only inside attribute VALUES / QName
-//     content, which the algorithm can't detect automatically).
 //   - No XML attribute inheritance (xml:lang/xml:space copy-down) — that is
 //     an Inclusive C14N 1.0 rule, not part of Exclusive C14N; implementing it
 //     here would produce non-interoperable output.
@@ -43,7 +44,8 @@ const (
 )
 
 type c14nConfig struct {
-	withComments bool
+	withComments      bool
+	inclusivePrefixes []string // InclusiveNamespaces PrefixList ("" = default namespace)
 }
 
 // C14NOption configures CanonicalizeXML / Canonicalize.
@@ -56,6 +58,24 @@ func WithComments() C14NOption {
 	return func(c *c14nConfig) { c.withComments = true }
 }
 
+// WithInclusiveNamespaces sets the InclusiveNamespaces PrefixList: the
+// declarations of these prefixes are rendered wherever they are in scope
+// (as Inclusive C14N would), not only where visibly used. "#default" stands
+// for the default namespace. The same list must be declared in the
+// signature, in an ec:InclusiveNamespaces element (see Signer.InclusiveNamespaces).
+//
+//	canon, err := xml.CanonicalizeXML(doc, xml.WithInclusiveNamespaces("cbc", "xsi"))
+func WithInclusiveNamespaces(prefixes ...string) C14NOption {
+	return func(c *c14nConfig) {
+		for _, p := range prefixes {
+			if p == "#default" {
+				p = ""
+			}
+			c.inclusivePrefixes = append(c.inclusivePrefixes, p)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Internal node tree
 // ---------------------------------------------------------------------------
@@ -81,6 +101,7 @@ type c14nNode struct {
 	local    string // element only
 	nsURI    string // element only: resolved namespace URI
 	attrs    []c14nAttrNode
+	scope    map[string]string // element only: prefix -> URI in scope ("" = default)
 	children []*c14nNode
 	text     string // text / comment only
 }
@@ -139,6 +160,7 @@ func parseC14NTree(xmlBytes []byte) (*c14nNode, error) {
 				prefix: t.Name.Space,
 				local:  t.Name.Local,
 				nsURI:  resolve(t.Name.Space),
+				scope:  scope,
 			}
 			for _, a := range realAttrs {
 				var ns string
@@ -220,6 +242,11 @@ func renderCanonicalNode(buf *bytes.Buffer, node *c14nNode, rendered map[string]
 			consider(a.prefix, a.nsURI)
 		}
 	}
+	for _, p := range cfg.inclusivePrefixes {
+		if uri, ok := node.scope[p]; ok {
+			consider(p, uri)
+		}
+	}
 
 	var needed []c14nNSDecl
 	for p, u := range pending {
@@ -307,12 +334,27 @@ func renderCanonicalNode(buf *bytes.Buffer, node *c14nNode, rendered map[string]
 
 // renderCanonicalized canonicalizes node as the root of its own scope (an
 // "isolated" render — see the package doc comment on Exclusive C14N above).
-func renderCanonicalized(node *c14nNode) ([]byte, error) {
+func renderCanonicalized(node *c14nNode, opts ...C14NOption) ([]byte, error) {
+	cfg := &c14nConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
 	var buf bytes.Buffer
-	renderCanonicalNode(&buf, node, map[string]string{}, &c14nConfig{})
+	renderCanonicalNode(&buf, node, map[string]string{}, cfg)
 	return buf.Bytes(), nil
 }
 
+// inclusiveNamespacesOf returns the options for the first
+// ec:InclusiveNamespaces element found in node (a ds:Reference or a
+// ds:CanonicalizationMethod), or nil if it declares none.
+func inclusiveNamespacesOf(node *c14nNode) []C14NOption {
+	inc := findElementNS(node, ExclusiveC14NAlgorithm, "InclusiveNamespaces")
+	if inc == nil {
+		return nil
+	}
+	return []C14NOption{WithInclusiveNamespaces(strings.Fields(attrValue(inc, "PrefixList"))...)}
+}
+
 // findElementNS searches node and its descendants (depth-first, node itself
 // included) for the first element matching (nsURI, local).
 func findElementNS(node *c14nNode, nsURI, local string) *c14nNode {
@@ -395,9 +437,8 @@ func nodeText(node *c14nNode) string {
 
 // cloneWithoutFirst returns a deep copy of node's subtree with the first
 // descendant-or-self element matching (nsURI, local) removed. Used to
-// implement the enveloped-signature transform at verification time (the
-// signer never needs this: its xmlContent is captured before the ds:
-// Signature element exists at all).
+// implement the enveloped-signature transform, both when signing (xmlContent
+// may already carry a ds:Signature) and at verification time.
 func cloneWithoutFirst(node *c14nNode, nsURI, local string) *c14nNode {
 	removed := false
 	return cloneWithoutFirstRec(node, nsURI, local, &removed)
@@ -410,6 +451,7 @@ func cloneWithoutFirstRec(node *c14nNode, nsURI, local string, removed *bool) *c
 		local:  node.local,
 		nsURI:  node.nsURI,
 		attrs:  node.attrs,
+		scope:  node.scope,
 		text:   node.text,
 	}
 	for _, child := range node.children {
@@ -84,6 +84,43 @@ func TestCanonicalizeXML_NamespaceRedeclared_WhenIsolated(t *testing.T) {
 	}
 }
 
+func TestCanonicalizeXML_InclusiveNamespaces(t *testing.T) {
+	in := `<inv:Invoice xmlns:inv="urn:inv" xmlns:cbc="urn:cbc" xmlns:xsi="urn:xsi" xmlns:unused="urn:u">` +
+		`<inv:Amount xsi:type="cbc:AmountType">10</inv:Amount></inv:Invoice>`
+
+	tests := []struct {
+		name string
+		opts []C14NOption
+		want string
+	}{
+		{
+			// cbc is only used inside an attribute value: dropped
+			"visibly used only", nil,
+			`<inv:Invoice xmlns:inv="urn:inv"><inv:Amount xmlns:xsi="urn:xsi" xsi:type="cbc:AmountType">10</inv:Amount></inv:Invoice>`,
+		},
+		{
+			// Listed prefixes are rendered where in scope, and only once
+			"prefix list", []C14NOption{WithInclusiveNamespaces("cbc", "xsi")},
+			`<inv:Invoice xmlns:cbc="urn:cbc" xmlns:inv="urn:inv" xmlns:xsi="urn:xsi"><inv:Amount xsi:type="cbc:AmountType">10</inv:Amount></inv:Invoice>`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := CanonicalizeXML([]byte(in), tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: CanonicalizeXML error: %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", tt.name, got, tt.want)
+		}
+	}
+
+	// "#default" names the default namespace
+	got, _ := CanonicalizeXML([]byte(`<a:r xmlns:a="urn:a" xmlns="urn:d"><a:c></a:c></a:r>`), WithInclusiveNamespaces("#default"))
+	if want := `<a:r xmlns="urn:d" xmlns:a="urn:a"><a:c></a:c></a:r>`; string(got) != want {
+		t.Errorf("#default:\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
 func TestCanonicalizeXML_EmptyElementsExpanded(t *testing.T) {
 	in := `<root><a/><b></b></root>`
 	got, err := CanonicalizeXML([]byte(in))
@@ -203,6 +240,32 @@ func TestCanonicalize_OrderedMap(t *testing.T) {
 	}
 }
 
+func TestCanonicalize_OrderedMap_NamespacesBeforeAttributes(t *testing.T) {
+	// Declarations mixed with attributes in key order; prefixed attributes
+	// sort by namespace URI ("urn:a" < "urn:z"), not by prefix.
+	inner := NewMap()
+	inner.Set("@z:id", "1")
+	inner.Set("@xmlns:z", "urn:a")
+	inner.Set("@b", "2")
+	inner.Set("@xmlns:a", "urn:z")
+	inner.Set("@a:id", "3")
+	inner.Set("@a", "4")
+	inner.Set("@xmlns", "urn:default")
+	inner.Set("z:Child", "value")
+
+	root := NewMap()
+	root.Put("Root", inner)
+
+	got, err := Canonicalize(root)
+	if err != nil {
+		t.Fatalf("Canonicalize error: %v", err)
+	}
+	want := `<Root xmlns="urn:default" xmlns:a="urn:z" xmlns:z="urn:a" a="4" b="2" z:id="1" a:id="3"><z:Child>value</z:Child></Root>`
+	if string(got) != want {
+		t.Errorf("got:  %s\nwant: %s", got, want)
+	}
+}
+
 func TestCanonicalize_ErrorsWithoutRootKey(t *testing.T) {
 	// Canonicalize now requires a document-shaped map (single root key),
 	// the same convention Marshal/MapXML already enforce elsewhere. A map
@@ -1,12 +1,19 @@
 package xml
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
+
+	"golang.org/x/crypto/pkcs12"
 )
 
 // ============================================================================
-// CERTIFICATE UTILITIES (Standard PEM Support)
+// CERTIFICATE UTILITIES (PEM and PKCS#12)
 // ============================================================================
 
 // LoadCert loads a certificate pair (Public + Private) from PEM files (.crt / .key).
@@ -21,13 +28,103 @@ func LoadCert(certFile, keyFile string) (tls.Certificate, error) {
 	return cert, nil
 }
 
-// LoadP12Cert is intentionally unimplemented: parsing PKCS#12 requires a
-// dependency this package deliberately doesn't have (go.mod has zero
-// requires). Convert the .p12/.pfx to PEM once with OpenSSL and use LoadCert
-// or NewSigner instead:
+// LoadP12Cert loads a PKCS#12 (.p12 / .pfx) file, the usual format of
+// DIAN/AEAT certificates, decrypting it with password. Intermediate CA
+// certificates bundled in the file follow the signer certificate in the
+// returned chain (tls.Certificate.Certificate), and Leaf is set.
+//
+// Only the legacy PKCS#12 encryption (3DES / RC2 with SHA-1) is supported.
+// Files exported with AES (the OpenSSL 3 default) fail to decode; re-export
+// them with:
 //
-//	openssl pkcs12 -in cert.p12 -out cert.pem -clcerts -nokeys
-//	openssl pkcs12 -in cert.p12 -out key.pem -nocerts -nodes
+//	openssl pkcs12 -in cert.p12 -nodes | openssl pkcs12 -export -legacy -out legacy.p12
 func LoadP12Cert(path, password string) (tls.Certificate, error) {
-	return tls.Certificate{}, fmt.Errorf("P12 support not implemented: convert to PEM with 'openssl pkcs12 -in %s -out cert.pem -clcerts -nokeys' and 'openssl pkcs12 -in %s -out key.pem -nocerts -nodes', then use LoadCert", path, path)
+	leaf, chain, key, err := decodeP12File(path, password)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert, nil
+}
+
+// NewSignerFromP12 is NewSigner for a PKCS#12 (.p12 / .pfx) file (see
+// LoadP12Cert). The signer uses the certificate matching the private key;
+// bundled CA certificates are ignored.
+func NewSignerFromP12(path, password string) (*Signer, error) {
+	leaf, _, key, err := decodeP12File(path, password)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{Cert: leaf, Key: key}, nil
+}
+
+// decodeP12File decodes a PKCS#12 file into the certificate of its private
+// key (leaf), the remaining certificates in file order (chain) and the key.
+func decodeP12File(path, password string) (leaf *x509.Certificate, chain []*x509.Certificate, key crypto.Signer, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// pkcs12.Decode only accepts exactly one certificate, so the bags are
+	// converted one by one to keep intermediate CAs working.
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode PKCS#12 %s: %w", path, err)
+	}
+
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to parse x509 certificate: %w", err)
+			}
+			certs = append(certs, c)
+		case "PRIVATE KEY":
+			if key != nil {
+				return nil, nil, nil, fmt.Errorf("PKCS#12 %s holds more than one private key", path)
+			}
+			if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+				key = k
+			} else if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+				key = k
+			} else {
+				return nil, nil, nil, fmt.Errorf("failed to parse private key: %w", err)
+			}
+		}
+	}
+	if key == nil {
+		return nil, nil, nil, fmt.Errorf("PKCS#12 %s holds no private key", path)
+	}
+
+	for _, c := range certs {
+		if leaf == nil && publicKeyMatches(c.PublicKey, key.Public()) {
+			leaf = c
+			continue
+		}
+		chain = append(chain, c)
+	}
+	if leaf == nil {
+		return nil, nil, nil, fmt.Errorf("PKCS#12 %s holds no certificate for its private key", path)
+	}
+	return leaf, chain, key, nil
+}
+
+// publicKeyMatches reports whether a certificate public key is pub.
+func publicKeyMatches(certKey, pub crypto.PublicKey) bool {
+	switch k := certKey.(type) {
+	case *rsa.PublicKey:
+		return k.Equal(pub)
+	case *ecdsa.PublicKey:
+		return k.Equal(pub)
+	}
+	return false
 }
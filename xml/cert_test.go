@@ -0,0 +1,55 @@
+package xml
+
+import (
+	"crypto/rsa"
+	"testing"
+)
+
+// testdata/chain.p12 (password "secret") holds a signer certificate issued
+// by an intermediate CA, the intermediate and the root, exported by
+// OpenSSL with PBE-SHA1-3DES.
+
+func TestLoadP12Cert(t *testing.T) {
+	cert, err := LoadP12Cert("testdata/chain.p12", "secret")
+	if err != nil {
+		t.Fatalf("LoadP12Cert failed: %v", err)
+	}
+	if cert.Leaf == nil || cert.Leaf.Subject.CommonName != "Test Signer" {
+		t.Fatalf("Leaf = %v, want CN=Test Signer", cert.Leaf)
+	}
+	if len(cert.Certificate) != 3 {
+		t.Fatalf("chain has %d certificates, want leaf + intermediate + root", len(cert.Certificate))
+	}
+	if _, ok := cert.PrivateKey.(*rsa.PrivateKey); !ok {
+		t.Errorf("PrivateKey = %T, want *rsa.PrivateKey", cert.PrivateKey)
+	}
+
+	if _, err := LoadP12Cert("testdata/chain.p12", "wrong"); err == nil {
+		t.Error("expected an error with a wrong password")
+	}
+	if _, err := LoadP12Cert("testdata/missing.p12", "secret"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestNewSignerFromP12(t *testing.T) {
+	s, err := NewSignerFromP12("testdata/chain.p12", "secret")
+	if err != nil {
+		t.Fatalf("NewSignerFromP12 failed: %v", err)
+	}
+	if s.Cert.Subject.CommonName != "Test Signer" {
+		t.Errorf("Cert = %s, want the signer certificate", s.Cert.Subject)
+	}
+
+	doc, inner := buildSignableDoc(t)
+	preSignBytes, _ := Marshal(doc)
+	sig, err := s.CreateSignature([]byte(preSignBytes))
+	if err != nil {
+		t.Fatalf("CreateSignature failed: %v", err)
+	}
+	inner.Set("ds:Signature", sig)
+	finalXML, _ := Marshal(doc)
+	if err := s.Verify([]byte(finalXML)); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+}
@@ -13,7 +13,7 @@ import (
 func getInputReader(args []string) (io.Reader, error) {
 	// If there are arguments and the first one is not a flag, we assume it's the file
 	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
-		f, err := os.Open(args[0])
+		f, err := openXMLFile(args[0])
 		if err != nil {
 			return nil, err
 		}
@@ -43,11 +43,10 @@ func CliFormat(args []string) {
 	}
 
 	// Write with PrettyPrint
-	enc := NewEncoder(os.Stdout, WithPrettyPrint())
+	enc := NewEncoder(os.Stdout, WithPrettyPrint(), WithTrailingNewline())
 	if err := enc.Encode(m); err != nil {
 		die(err)
 	}
-	fmt.Println()
 }
 
 // 2. JSON Converter
@@ -64,15 +63,42 @@ func CliToJson(args []string) {
 	fmt.Println(string(b))
 }
 
+// 2b. YAML Converter
+// Usage: r2xml yaml data.xml
+func CliToYaml(args []string) {
+	r, err := getInputReader(args)
+	if err != nil {
+		die(err)
+	}
+	m, err := MapXML(r, EnableLegacyCharsets())
+	if err != nil {
+		die(err)
+	}
+	out, err := ToYAML(m)
+	if err != nil {
+		die(err)
+	}
+	fmt.Print(out)
+}
+
 // 3. CSV Converter (Flatten Lists)
-// Usage: r2xml csv data.xml --path="orders/order"
+// Usage: r2xml csv data.xml --path="orders/order" [--delim=tab]
+// Records are streamed, so the columns are those of the first record at
+// --path; fields that only appear in later records are not exported.
 func CliToCsv(args []string) {
 	var targetPath string
+	delim := ','
 	// Simple manual args parsing
 	cleanArgs := []string{}
 	for _, a := range args {
 		if strings.HasPrefix(a, "--path=") {
 			targetPath = strings.TrimPrefix(a, "--path=")
+		} else if strings.HasPrefix(a, "--delim=") {
+			d, err := parseDelimiter(strings.TrimPrefix(a, "--delim="))
+			if err != nil {
+				die(err)
+			}
+			delim = d
 		} else {
 			cleanArgs = append(cleanArgs, a)
 		}
@@ -87,24 +113,40 @@ func CliToCsv(args []string) {
 		die(err)
 	}
 
-	// Force an array at the target path to guarantee a list
-	nodeName := getLastSegment(targetPath)
-	m, err := MapXML(r, ForceArray(nodeName))
-	if err != nil {
+	// Stream the records so huge lists keep memory flat. Only elements at
+	// the full --path are records, as with MapXML(...).List(path).
+	out := &countingWriter{w: os.Stdout}
+	if err := streamDelimited(out, newPathStream[*OrderedMap](r, targetPath), nil, delim); err != nil {
 		die(err)
 	}
-
-	// Extract the list
-	list := m.List(targetPath)
-	if len(list) == 0 {
+	if out.n == 0 {
 		fmt.Fprintln(os.Stderr, "No rows found at path:", targetPath)
-		return
 	}
+}
 
-	// Convert
-	if err := ToCSV(os.Stdout, list); err != nil {
-		die(err)
+// parseDelimiter reads a --delim value: a single character, or "tab" / "\t"
+// for TSV.
+func parseDelimiter(s string) (rune, error) {
+	if s == "tab" || s == `\t` {
+		return '\t', nil
+	}
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\n' || r[0] == '\r' {
+		return 0, fmt.Errorf("invalid delimiter %q: use a single character or \"tab\"", s)
 	}
+	return r[0], nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // 4. Query
@@ -202,11 +244,11 @@ func CliSoap(args []string) {
 	// === OUTPUT LOGIC ===
 	if cfg.Output == "xml" {
 		// Use the encoder's Marshal to output plain XML
-		s, err := Marshal(resp)
+		s, err := Marshal(resp, WithTrailingNewline())
 		if err != nil {
 			die(err)
 		}
-		fmt.Println(s)
+		fmt.Print(s)
 	} else {
 		// Default: JSON Dump
 		fmt.Println(resp.Dump())
@@ -250,6 +292,129 @@ func CliWSDL(args []string) {
 	}
 }
 
+// 8. Canonicalization (Exclusive C14N)
+// Usage: r2xml c14n signed.xml [--with-comments]
+// Writes the exact canonical bytes (no trailing newline) so the output can be
+// piped into a hash tool to debug signature digest mismatches.
+func CliCanonicalize(args []string) {
+	var opts []C14NOption
+	cleanArgs := []string{}
+	for _, a := range args {
+		if a == "--with-comments" {
+			opts = append(opts, WithComments())
+		} else {
+			cleanArgs = append(cleanArgs, a)
+		}
+	}
+
+	r, err := getInputReader(cleanArgs)
+	if err != nil {
+		die(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		die(err)
+	}
+
+	// Raw bytes (not MapXML): the canonical form depends on the literal
+	// namespace prefixes, which the OrderedMap does not keep.
+	out, err := CanonicalizeXML(data, opts...)
+	if err != nil {
+		die(err)
+	}
+	os.Stdout.Write(out)
+}
+
+// 9. JSON to XML
+// Usage: r2xml json2xml data.json [--root=Invoice]
+func CliJsonToXml(args []string) {
+	rootName := ""
+	cleanArgs := []string{}
+	for _, a := range args {
+		if strings.HasPrefix(a, "--root=") {
+			rootName = strings.TrimPrefix(a, "--root=")
+		} else {
+			cleanArgs = append(cleanArgs, a)
+		}
+	}
+
+	r, err := getInputReader(cleanArgs)
+	if err != nil {
+		die(err)
+	}
+	m, err := decodeJSONDocument(r, rootName)
+	if err != nil {
+		die(err)
+	}
+
+	enc := NewEncoder(os.Stdout, WithPrettyPrint(), WithTrailingNewline())
+	if err := enc.Encode(m); err != nil {
+		die(err)
+	}
+}
+
+// 10. Validation
+// Usage: r2xml validate invoice.xml --rules=rules.json
+// rules.json is a JSON array of Rule objects:
+//
+//	[{"path": "Invoice/ID", "required": true},
+//	 {"path": "Invoice/Total", "type": "float", "min": 1}]
+//
+// Prints each failure and exits with status 1 if there is any.
+func CliValidate(args []string) {
+	failures, err := validateCLI(args, os.Stdout)
+	if err != nil {
+		die(err)
+	}
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
+
+// validateCLI runs the validate command, writing the report to w, and
+// returns the number of failures.
+func validateCLI(args []string, w io.Writer) (int, error) {
+	rulesPath := ""
+	cleanArgs := []string{}
+	for _, a := range args {
+		if strings.HasPrefix(a, "--rules=") {
+			rulesPath = strings.TrimPrefix(a, "--rules=")
+		} else {
+			cleanArgs = append(cleanArgs, a)
+		}
+	}
+	if rulesPath == "" {
+		return 0, fmt.Errorf("missing --rules=<rules.json>")
+	}
+
+	rulesData, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return 0, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(rulesData, &rules); err != nil {
+		return 0, fmt.Errorf("invalid rules file: %w", err)
+	}
+
+	r, err := getInputReader(cleanArgs)
+	if err != nil {
+		return 0, err
+	}
+	m, err := MapXML(r, EnableLegacyCharsets())
+	if err != nil {
+		return 0, err
+	}
+
+	errs := Validate(m, rules)
+	for _, e := range errs {
+		fmt.Fprintln(w, e)
+	}
+	if len(errs) == 0 {
+		fmt.Fprintf(w, "OK (%d rules)\n", len(rules))
+	}
+	return len(errs), nil
+}
+
 func partNames(parts []WSDLPart) string {
 	names := make([]string, len(parts))
 	for i, p := range parts {
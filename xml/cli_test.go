@@ -128,6 +128,67 @@ func TestCliToJson(t *testing.T) {
 	}
 }
 
+func TestCliToYaml(t *testing.T) {
+	path := writeTempFile(t, "in.xml", `<root><a>x</a><b id="1">y</b></root>`)
+
+	out := captureStdout(t, func() {
+		CliToYaml([]string{path})
+	})
+
+	want := "root:\n  a: x\n  b:\n    \"@id\": \"1\"\n    \"#text\": \"y\"\n"
+	if out != want {
+		t.Errorf("CliToYaml output = %q, want %q", out, want)
+	}
+}
+
+func TestCliJsonToXml(t *testing.T) {
+	path := writeTempFile(t, "in.json", `{"id":7,"tag":["a","b"]}`)
+
+	out := captureStdout(t, func() {
+		CliJsonToXml([]string{path, "--root=order"})
+	})
+
+	want := "\n<order>\n  <id>7</id>\n  <tag>a</tag>\n  <tag>b</tag>\n</order>\n"
+	if out != want {
+		t.Errorf("CliJsonToXml output = %q, want %q", out, want)
+	}
+}
+
+func TestCliValidate(t *testing.T) {
+	rules := writeTempFile(t, "rules.json", `[
+		{"path": "Invoice/ID", "required": true},
+		{"path": "Invoice/Total", "type": "float", "min": 1},
+		{"path": "Invoice/Currency", "type": "string", "enum": ["COP", "USD"]}
+	]`)
+	valid := writeTempFile(t, "ok.xml", `<Invoice><ID>1</ID><Total>10</Total><Currency>COP</Currency></Invoice>`)
+	invalid := writeTempFile(t, "bad.xml", `<Invoice><Total>-5</Total><Currency>EUR</Currency></Invoice>`)
+
+	out := captureStdout(t, func() {
+		CliValidate([]string{valid, "--rules=" + rules})
+	})
+	if out != "OK (3 rules)\n" {
+		t.Errorf("CliValidate output = %q", out)
+	}
+
+	var buf strings.Builder
+	n, err := validateCLI([]string{"--rules=" + rules, invalid}, &buf)
+	if err != nil {
+		t.Fatalf("validateCLI error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("failures = %d, want 3:\n%s", n, buf.String())
+	}
+	for _, want := range []string{"Missing: Invoice/ID", "less than minimum", "Invoice/Currency invalid value"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	if _, err := validateCLI([]string{valid}, io.Discard); err == nil {
+		t.Error("expected an error without --rules")
+	}
+}
+
 func TestCliToCsv(t *testing.T) {
 	path := writeTempFile(t, "in.xml", `<orders><order><id>1</id></order><order><id>2</id></order></orders>`)
 
@@ -143,6 +204,54 @@ func TestCliToCsv(t *testing.T) {
 	}
 }
 
+func TestCliToCsv_Streaming(t *testing.T) {
+	// The nested <order> is not at orders/order, and <note> only appears
+	// after the first record, which sets the columns.
+	path := writeTempFile(t, "in.xml", `<orders>
+		<order><id>1</id></order>
+		<archive><order><id>old</id></order></archive>
+		<order><id>2</id><note>late</note></order>
+	</orders>`)
+
+	out := captureStdout(t, func() {
+		CliToCsv([]string{path, "--path=orders/order"})
+	})
+	if want := "id\n1\n2\n"; out != want {
+		t.Errorf("CliToCsv = %q, want %q", out, want)
+	}
+}
+
+func TestCliToCsv_Delimiter(t *testing.T) {
+	path := writeTempFile(t, "in.xml", `<orders><order><id>1</id><note>a, b</note></order></orders>`)
+
+	out := captureStdout(t, func() {
+		CliToCsv([]string{path, "--path=orders/order", "--delim=tab"})
+	})
+	if want := "id\tnote\n1\ta, b\n"; out != want {
+		t.Errorf("CliToCsv --delim=tab = %q, want %q", out, want)
+	}
+
+	tests := []struct {
+		in      string
+		want    rune
+		wantErr bool
+	}{
+		{"tab", '\t', false},
+		{`\t`, '\t', false},
+		{";", ';', false},
+		{"|", '|', false},
+		{"", 0, true},
+		{";;", 0, true},
+		{`"`, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDelimiter(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDelimiter(%q) = %q, %v; want %q (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
 func TestCliQuery(t *testing.T) {
 	path := writeTempFile(t, "in.xml", `<root><a>1</a></root>`)
 
@@ -253,3 +362,21 @@ func TestCliSoapQuick_WithWSDL(t *testing.T) {
 		t.Errorf("CliSoapQuick --wsdl output missing expected content: %q", out)
 	}
 }
+
+func TestCliCanonicalize(t *testing.T) {
+	path := writeTempFile(t, "in.xml", `<root b="2" a="1"><!--note--><child/></root>`)
+
+	out := captureStdout(t, func() {
+		CliCanonicalize([]string{path})
+	})
+	if want := `<root a="1" b="2"><child></child></root>`; out != want {
+		t.Errorf("CliCanonicalize output = %q, want %q", out, want)
+	}
+
+	out = captureStdout(t, func() {
+		CliCanonicalize([]string{path, "--with-comments"})
+	})
+	if want := `<root a="1" b="2"><!--note--><child></child></root>`; out != want {
+		t.Errorf("CliCanonicalize --with-comments output = %q, want %q", out, want)
+	}
+}
@@ -1,10 +1,27 @@
 package xml
 
 import (
-	"crypto/sha512"
-	"fmt"
+	"crypto"
+	_ "crypto/sha256" // registers SHA-256 for ComputeHashCode
+	_ "crypto/sha512" // registers SHA-384/512 for ComputeHashCode
+	"encoding/hex"
+	"strings"
 )
 
+// ComputeHashCode concatenates fields in the given order (no separators)
+// and returns the lowercase hex digest under alg. It is the building block
+// of e-invoicing codes such as CUFE/CUDE, which differ only in field order
+// and algorithm. Returns "" if alg is not linked into the binary
+// (SHA-256, SHA-384 and SHA-512 always are).
+func ComputeHashCode(fields []string, alg crypto.Hash) string {
+	if !alg.Available() {
+		return ""
+	}
+	h := alg.New()
+	h.Write([]byte(strings.Join(fields, "")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // CalculateCUFE generates the mandatory SHA-384 hash.
 // ClaveTecnica: provided by DIAN in the enablement portal.
 func CalculateCUFE(
@@ -25,17 +42,43 @@ func CalculateCUFE(
 
 	// The DIAN formula is strict about this order:
 	// NumFac + FecFac + HorFac + ValFac + CodImp1 + ValImp1 + CodImp2 + ValImp2 + ValTot + NitEmi + NumAdq + ClaveTec + TipoAmb
-
-	raw := fmt.Sprintf("%s%s%s%s%s%s%s%s%s%s%s%s%s",
+	return ComputeHashCode([]string{
 		NumFac, FecFac, HorFac, ValFac,
 		CodImp1, ValImp1,
 		CodImp2, ValImp2,
 		ValTot,
-		NitEmi, NumAdq, ClaveTec, TipoAmb)
+		NitEmi, NumAdq, ClaveTec, TipoAmb,
+	}, crypto.SHA384)
+}
 
-	// Debugging: Print to verify what we are hashing
-	// fmt.Println("DEBUG CUFE String:", raw)
+// CalculateCUDE generates the SHA-384 code of credit/debit notes and other
+// electronic documents. Unlike the CUFE it carries a third tax (03, ICA)
+// and is keyed with the software PIN instead of the technical key.
+func CalculateCUDE(
+	NumDoc string, // NC-100
+	FecDoc string, // 2025-12-19
+	HorDoc string, // 12:00:00-05:00
+	ValDoc string, // 1000.00 (Total without taxes)
+	CodImp1 string, // 01 (VAT)
+	ValImp1 string, // 190.00
+	CodImp2 string, // 04 (Consumption)
+	ValImp2 string, // 0.00
+	CodImp3 string, // 03 (ICA)
+	ValImp3 string, // 0.00
+	ValTot string, // 1190.00 (Total + Taxes)
+	NitEmi string, // 900123456
+	NumAdq string, // 222222222222
+	PinSoftware string, // Software PIN registered with DIAN
+	TipoAmb string, // 2 = Testing, 1 = Production
+) string {
 
-	hash := sha512.Sum384([]byte(raw))
-	return fmt.Sprintf("%x", hash)
+	// NumDoc + FecDoc + HorDoc + ValDoc + CodImp1 + ValImp1 + CodImp2 + ValImp2 + CodImp3 + ValImp3 + ValTot + NitEmi + NumAdq + PinSoftware + TipoAmb
+	return ComputeHashCode([]string{
+		NumDoc, FecDoc, HorDoc, ValDoc,
+		CodImp1, ValImp1,
+		CodImp2, ValImp2,
+		CodImp3, ValImp3,
+		ValTot,
+		NitEmi, NumAdq, PinSoftware, TipoAmb,
+	}, crypto.SHA384)
 }
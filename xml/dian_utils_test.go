@@ -1,6 +1,9 @@
 package xml
 
-import "testing"
+import (
+	"crypto"
+	"testing"
+)
 
 func TestCalculateCUFE(t *testing.T) {
 	// Known vector: sha384("01-1002025-12-1912:00:00-05:001000.0001190.00040.001190.00900123456222222222222claveTecnicaPruebas2")
@@ -45,3 +48,25 @@ func TestCalculateCUFE_FieldOrderMatters(t *testing.T) {
 		t.Fatal("CalculateCUFE() did not change when field values were swapped")
 	}
 }
+
+func TestCalculateCUDE(t *testing.T) {
+	// Known vector: sha384("NC-1002025-12-1912:00:00-05:001000.0001190.00040.00030.001190.00900123456222222222222123452")
+	const want = "a6d218ad36957e67dd1db4f41618046fcc141f1a7f0790f4017627af14c03c1edff5c5b130ff739dab190f741e91c6fc"
+
+	got := CalculateCUDE("NC-100", "2025-12-19", "12:00:00-05:00", "1000.00",
+		"01", "190.00", "04", "0.00", "03", "0.00", "1190.00",
+		"900123456", "222222222222", "12345", "2")
+
+	if got != want {
+		t.Fatalf("CalculateCUDE() = %s, want %s", got, want)
+	}
+}
+
+func TestComputeHashCode(t *testing.T) {
+	if got := ComputeHashCode([]string{"a", "b", "c"}, crypto.SHA256); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("ComputeHashCode(SHA256) = %s", got)
+	}
+	if got := ComputeHashCode([]string{"abc"}, crypto.Hash(0)); got != "" {
+		t.Errorf("ComputeHashCode with an unavailable hash = %q, want empty", got)
+	}
+}
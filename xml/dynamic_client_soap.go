@@ -1,10 +1,14 @@
 package xml
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 )
@@ -28,6 +32,9 @@ const (
 const (
 	soap11EnvelopeNS = "http://schemas.xmlsoap.org/soap/envelope/"
 	soap12EnvelopeNS = "http://www.w3.org/2003/05/soap-envelope"
+
+	wsuNS         = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+	wsuTimeLayout = "2006-01-02T15:04:05.000Z"
 )
 
 // SoapClient allows dynamic calls to SOAP services without structs.
@@ -37,12 +44,14 @@ type SoapClient struct {
 	HttpClient     *http.Client
 	SoapActionBase string
 	Headers        map[string]string
+	UserAgent      string
 	Version        SoapVersion
 
 	AuthType     string
 	AuthUsername string
 	AuthPassword string
 	AuthToken    string
+	TimestampTTL time.Duration // > 0 adds a wsu:Timestamp to the WS-Security header
 
 	// --- mTLS Config ---
 	CertFile string
@@ -52,6 +61,12 @@ type SoapClient struct {
 	// --- Retry ---
 	RetryAttempts int           // 0 or 1 = no retries
 	RetryBackoff  time.Duration // fixed wait between attempts
+
+	// --- Streaming ---
+	StreamRequests bool // encode the envelope straight into the request body (see WithStreamingRequests)
+
+	// --- Debug ---
+	RequestDump io.Writer // receives every request as sent (see WithRequestDump)
 }
 
 // --- mTLS Options ---
@@ -77,10 +92,19 @@ func WithTimeout(d time.Duration) ClientOption {
 	return func(s *SoapClient) { s.HttpClient.Timeout = d }
 }
 
+// WithHeader adds a custom HTTP header to every request. Custom headers are
+// applied last, so they always win over the defaults (Content-Type,
+// SOAPAction, User-Agent, Authorization); an empty value removes that
+// header from the request instead.
 func WithHeader(key, value string) ClientOption {
 	return func(s *SoapClient) { s.Headers[key] = value }
 }
 
+// WithUserAgent overrides the default "r2-xml-client/2.0" User-Agent.
+func WithUserAgent(ua string) ClientOption {
+	return func(s *SoapClient) { s.UserAgent = ua }
+}
+
 func WithSoapActionBase(base string) ClientOption {
 	return func(s *SoapClient) { s.SoapActionBase = base }
 }
@@ -102,6 +126,22 @@ func WithRetry(attempts int, backoff time.Duration) ClientOption {
 	}
 }
 
+// WithRequestDump writes each outgoing request (endpoint, HTTP headers and
+// envelope) to w just before it is sent, once per attempt. Headers are
+// dumped as sent, so Authorization credentials end up in w too.
+func WithRequestDump(w io.Writer) ClientOption {
+	return func(s *SoapClient) { s.RequestDump = w }
+}
+
+// WithStreamingRequests pipes the Encoder straight into the request body
+// instead of encoding the whole envelope into memory first, so large batch
+// payloads are sent as they are encoded (chunked, without Content-Length).
+// Each retry re-encodes the payload. An encoding error aborts the request
+// and is returned as a network error.
+func WithStreamingRequests() ClientOption {
+	return func(s *SoapClient) { s.StreamRequests = true }
+}
+
 // --- Auth Options ---
 
 func WithBasicAuth(user, pass string) ClientOption {
@@ -127,6 +167,14 @@ func WithWSSecurity(user, pass string) ClientOption {
 	}
 }
 
+// WithWSSecurityTimestamp adds a wsu:Timestamp to the WS-Security header of
+// every request, with Created set to the send time and Expires ttl later
+// (UTC). It can be combined with WithWSSecurity or used on its own. The
+// Timestamp carries wsu:Id="TS-1" so a signature can reference it.
+func WithWSSecurityTimestamp(ttl time.Duration) ClientOption {
+	return func(s *SoapClient) { s.TimestampTTL = ttl }
+}
+
 // NewSoapClient creates a new client.
 func NewSoapClient(endpoint, namespace string, opts ...ClientOption) *SoapClient {
 	client := &SoapClient{
@@ -134,6 +182,7 @@ func NewSoapClient(endpoint, namespace string, opts ...ClientOption) *SoapClient
 		Namespace:   namespace,
 		HttpClient:  &http.Client{Timeout: 30 * time.Second},
 		Headers:     make(map[string]string),
+		UserAgent:   "r2-xml-client/2.0",
 		AuthType:    AuthNone,
 	}
 	for _, opt := range opts {
@@ -229,10 +278,31 @@ func extractSoapFault(respMap *OrderedMap) *SoapFault {
 	return sf
 }
 
+// BuildPayload builds a nested SOAP payload from flat "Path/To/Key" -> value
+// pairs (e.g. web form data), the same way `r2xml call --data` does:
+//
+//	BuildPayload(map[string]string{"User/Id": "100", "User/Active": "true"})
+//	// -> <User><Active>true</Active><Id>100</Id></User>
+//
+// Go maps have no order, so keys are applied alphabetically; build an
+// *OrderedMap with Set directly when the service requires a specific order.
+func BuildPayload(pairs map[string]string) *OrderedMap {
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	payload := NewMap()
+	for _, k := range keys {
+		payload.Set(k, inferCLIValue(pairs[k]))
+	}
+	return payload
+}
+
 // buildEnvelope constructs the soap:Envelope (payload, WS-Security header,
-// body) for action/payload and returns its encoded bytes. Shared by Call and
-// CallOperation.
-func (c *SoapClient) buildEnvelope(action string, payload any) ([]byte, error) {
+// body) for action/payload. Shared by Call, CallStream and CallOperation.
+func (c *SoapClient) buildEnvelope(action string, payload any) (*OrderedMap, error) {
 	// 1. Prepare the Payload
 	actionNode := NewMap()
 	actionNode.Put("@xmlns", c.Namespace)
@@ -263,19 +333,33 @@ func (c *SoapClient) buildEnvelope(action string, payload any) ([]byte, error) {
 	envelopeMap.Put("@xmlns:soap", envelopeNS)
 
 	// 3. Inject WS-Security (if applicable) - Headers go BEFORE the Body
-	if c.AuthType == AuthWSSecurity {
+	if c.AuthType == AuthWSSecurity || c.TimestampTTL > 0 {
 		security := NewMap()
 		security.Put("@xmlns:wsse", "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd")
 
-		usernameToken := NewMap()
-		usernameToken.Put("wsse:Username", c.AuthUsername)
+		// Timestamp first: receivers commonly expect it at the top of the
+		// Security header.
+		if c.TimestampTTL > 0 {
+			security.Put("@xmlns:wsu", wsuNS)
+			created := time.Now().UTC()
+			timestamp := NewMap()
+			timestamp.Put("@wsu:Id", "TS-1")
+			timestamp.Put("wsu:Created", created.Format(wsuTimeLayout))
+			timestamp.Put("wsu:Expires", created.Add(c.TimestampTTL).Format(wsuTimeLayout))
+			security.Put("wsu:Timestamp", timestamp)
+		}
 
-		password := NewMap()
-		password.Put("@Type", "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordText")
-		password.Put("#text", c.AuthPassword)
-		usernameToken.Put("wsse:Password", password)
+		if c.AuthType == AuthWSSecurity {
+			usernameToken := NewMap()
+			usernameToken.Put("wsse:Username", c.AuthUsername)
 
-		security.Put("wsse:UsernameToken", usernameToken)
+			password := NewMap()
+			password.Put("@Type", "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordText")
+			password.Put("#text", c.AuthPassword)
+			usernameToken.Put("wsse:Password", password)
+
+			security.Put("wsse:UsernameToken", usernameToken)
+		}
 
 		header := NewMap()
 		header.Put("wsse:Security", security)
@@ -289,20 +373,65 @@ func (c *SoapClient) buildEnvelope(action string, payload any) ([]byte, error) {
 
 	envelope := NewMap()
 	envelope.Put("soap:Envelope", envelopeMap)
+	return envelope, nil
+}
 
-	// 5. Encode
-	var buf bytes.Buffer
-	enc := NewEncoder(&buf)
-	if err := enc.Encode(envelope); err != nil {
-		return nil, fmt.Errorf("failed to encode SOAP request: %w", err)
-	}
-	return buf.Bytes(), nil
+// pipeEnvelope returns a reader that yields envelope as the Encoder writes
+// it (WithStreamingRequests).
+func pipeEnvelope(envelope *OrderedMap) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		bw := bufio.NewWriter(pw)
+		err := NewEncoder(bw).Encode(envelope)
+		if err == nil {
+			err = bw.Flush()
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to encode SOAP request: %w", err)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
 }
 
-// doCall sends bodyBytes to the endpoint with the given exact soapAction
+// doCall sends envelope to the endpoint with the given exact soapAction
 // (retrying on transport errors per WithRetry), parses the response, and
 // surfaces a *SoapFault for non-2xx responses that carry one.
-func (c *SoapClient) doCall(bodyBytes []byte, soapAction string) (*OrderedMap, error) {
+func (c *SoapClient) doCall(envelope *OrderedMap, soapAction string) (*OrderedMap, error) {
+	resp, err := c.send(context.Background(), envelope, soapAction)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respMap, err := MapXML(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response (status %d): %w", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if fault := extractSoapFault(respMap); fault != nil {
+			return nil, fault
+		}
+		return nil, fmt.Errorf("http error %d", resp.StatusCode)
+	}
+
+	return respMap, nil
+}
+
+// send posts envelope with the given soapAction, retrying on transport
+// errors per WithRetry. The request is bound to ctx. The caller must close
+// the response body.
+func (c *SoapClient) send(ctx context.Context, envelope *OrderedMap, soapAction string) (*http.Response, error) {
+	var bodyBytes []byte
+	if !c.StreamRequests {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(envelope); err != nil {
+			return nil, fmt.Errorf("failed to encode SOAP request: %w", err)
+		}
+		bodyBytes = buf.Bytes()
+	}
+
 	attempts := c.RetryAttempts
 	if attempts < 1 {
 		attempts = 1
@@ -317,7 +446,13 @@ func (c *SoapClient) doCall(bodyBytes []byte, soapAction string) (*OrderedMap, e
 			}
 		}
 
-		req, err := http.NewRequest("POST", c.EndpointURL, bytes.NewReader(bodyBytes))
+		var body io.Reader
+		if c.StreamRequests {
+			body = pipeEnvelope(envelope)
+		} else {
+			body = bytes.NewReader(bodyBytes)
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", c.EndpointURL, body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
@@ -328,7 +463,7 @@ func (c *SoapClient) doCall(bodyBytes []byte, soapAction string) (*OrderedMap, e
 			req.Header.Set("Content-Type", "text/xml; charset=utf-8")
 			req.Header.Set("SOAPAction", fmt.Sprintf("\"%s\"", soapAction))
 		}
-		req.Header.Set("User-Agent", "r2-xml-client/2.0")
+		req.Header.Set("User-Agent", c.UserAgent)
 
 		switch c.AuthType {
 		case AuthBasic:
@@ -337,7 +472,30 @@ func (c *SoapClient) doCall(bodyBytes []byte, soapAction string) (*OrderedMap, e
 			req.Header.Set("Authorization", "Bearer "+c.AuthToken)
 		}
 		for k, v := range c.Headers {
-			req.Header.Set(k, v)
+			if v != "" {
+				req.Header.Set(k, v)
+			} else if http.CanonicalHeaderKey(k) == "User-Agent" {
+				// net/http sends its own User-Agent when the header is
+				// absent; an explicit empty value is what suppresses it.
+				req.Header.Set(k, "")
+			} else {
+				req.Header.Del(k)
+			}
+		}
+
+		if c.RequestDump != nil {
+			fmt.Fprintf(c.RequestDump, "POST %s\n", c.EndpointURL)
+			req.Header.Write(c.RequestDump)
+			if c.StreamRequests {
+				// The body is dumped as it is sent (no trailing newline)
+				fmt.Fprint(c.RequestDump, "\n")
+				req.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.TeeReader(req.Body, c.RequestDump), req.Body}
+			} else {
+				fmt.Fprintf(c.RequestDump, "\n%s\n", bodyBytes)
+			}
 		}
 
 		resp, lastErr = c.HttpClient.Do(req)
@@ -348,21 +506,7 @@ func (c *SoapClient) doCall(bodyBytes []byte, soapAction string) (*OrderedMap, e
 	if lastErr != nil {
 		return nil, fmt.Errorf("soap call network error: %w", lastErr)
 	}
-	defer resp.Body.Close()
-
-	respMap, err := MapXML(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse response (status %d): %w", resp.StatusCode, err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		if fault := extractSoapFault(respMap); fault != nil {
-			return nil, fault
-		}
-		return nil, fmt.Errorf("http error %d", resp.StatusCode)
-	}
-
-	return respMap, nil
+	return resp, nil
 }
 
 // Call executes a SOAP action.
@@ -371,20 +515,95 @@ func (c *SoapClient) doCall(bodyBytes []byte, soapAction string) (*OrderedMap, e
 // a convention that does not match the real soapAction of many services.
 // If you have the WSDL, use CallOperation for the exact value.
 func (c *SoapClient) Call(action string, payload any) (*OrderedMap, error) {
-	bodyBytes, err := c.buildEnvelope(action, payload)
+	envelope, err := c.buildEnvelope(action, payload)
 	if err != nil {
 		return nil, err
 	}
+	return c.doCall(envelope, c.guessSoapAction(action))
+}
 
+// guessSoapAction builds Call's "namespace/action" SOAPAction.
+func (c *SoapClient) guessSoapAction(action string) string {
 	base := c.Namespace
 	if c.SoapActionBase != "" {
 		base = c.SoapActionBase
 	}
 	cleanBase := strings.TrimSuffix(base, "/")
 	cleanAction := strings.TrimPrefix(action, "/")
-	soapAction := fmt.Sprintf("%s/%s", cleanBase, cleanAction)
+	return fmt.Sprintf("%s/%s", cleanBase, cleanAction)
+}
+
+// CallStream executes a SOAP action like Call, but instead of building the
+// response tree it streams every tagName element of the response body as a
+// T (see NewStream), so multi-megabyte responses are decoded as they
+// arrive. Faults and HTTP errors are returned before any item is sent.
+//
+// The channel is closed at the end of the response, on a decode error or
+// when ctx is done; the returned func then reports why it stopped (nil at
+// the end of the response, the decode error or ctx.Err()). It blocks until
+// the channel is closed. Cancel ctx to stop early: the connection is
+// released and no goroutine is left behind. Note that the client Timeout
+// (WithTimeout) covers reading the whole body.
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	defer cancel()
+//	records, errFn, err := xml.CallStream[Record](ctx, client, "Export", payload, "Record")
+//	if err != nil { ... }
+//	for r := range records { ... }
+//	if err := errFn(); err != nil { ... }
+func CallStream[T any](ctx context.Context, c *SoapClient, action string, payload any, tagName string) (<-chan T, func() error, error) {
+	envelope, err := c.buildEnvelope(action, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := c.send(ctx, envelope, c.guessSoapAction(action))
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return c.doCall(bodyBytes, soapAction)
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if respMap, err := MapXML(resp.Body); err == nil {
+			if fault := extractSoapFault(respMap); fault != nil {
+				return nil, nil, fault
+			}
+		}
+		return nil, nil, fmt.Errorf("http error %d", resp.StatusCode)
+	}
+
+	stream := NewStream[T](resp.Body, tagName)
+	ch := make(chan T)
+	done := make(chan struct{})
+	var streamErr error
+	go func() {
+		defer close(done)
+		defer close(ch)
+		defer resp.Body.Close()
+		for {
+			item, err := stream.next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				// Reading a canceled request fails too; report the cause
+				if streamErr = ctx.Err(); streamErr == nil {
+					streamErr = err
+				}
+				return
+			}
+			select {
+			case ch <- item:
+			case <-ctx.Done():
+				streamErr = ctx.Err()
+				return
+			}
+		}
+	}()
+	errFn := func() error {
+		<-done
+		return streamErr
+	}
+	return ch, errFn, nil
 }
 
 // CallOperation executes action using the exact soapAction, endpoint and
@@ -396,12 +615,12 @@ func (c *SoapClient) CallOperation(w *WSDL, action string, payload any) (*Ordere
 		return nil, err
 	}
 
-	bodyBytes, err := c.buildEnvelope(action, payload)
+	envelope, err := c.buildEnvelope(action, payload)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.doCall(bodyBytes, op.SOAPAction)
+	return c.doCall(envelope, op.SOAPAction)
 }
 
 // NewSoapClientFromWSDL builds a SoapClient using the first SOAP endpoint
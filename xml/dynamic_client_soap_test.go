@@ -1,6 +1,7 @@
 package xml
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -414,3 +415,114 @@ func TestNewSoapClientFromWSDL(t *testing.T) {
 		t.Errorf("Version = %v, want Soap11 (matching the first port)", client.Version)
 	}
 }
+
+func TestCallStream(t *testing.T) {
+	type record struct {
+		ID   int    `xml:"id,attr"`
+		Name string `xml:"Name"`
+	}
+
+	const n = 2000
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// No Content-Length: the response goes out chunked
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><ExportResponse>`)
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(w, `<Record id="%d"><Name>R%d</Name></Record>`, i, i)
+			if i%500 == 0 {
+				w.(http.Flusher).Flush()
+			}
+		}
+		fmt.Fprint(w, `</ExportResponse></soap:Body></soap:Envelope>`)
+	}))
+	defer ts.Close()
+
+	client := NewSoapClient(ts.URL, "http://example.org/export")
+	records, errFn, err := CallStream[record](context.Background(), client, "Export", NewMap(), "Record")
+	if err != nil {
+		t.Fatalf("CallStream failed: %v", err)
+	}
+
+	count := 0
+	for r := range records {
+		count++
+		if r.ID != count || r.Name != fmt.Sprintf("R%d", count) {
+			t.Fatalf("record %d = %+v", count, r)
+		}
+	}
+	if count != n {
+		t.Errorf("streamed %d records, want %d", count, n)
+	}
+	if err := errFn(); err != nil {
+		t.Errorf("errFn after a full response = %v", err)
+	}
+}
+
+func TestCallStream_DecodeError(t *testing.T) {
+	type record struct {
+		ID int `xml:"id,attr"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><ExportResponse><Record id="1"/><Record id="x"/>`)
+	}))
+	defer ts.Close()
+
+	records, errFn, err := CallStream[record](context.Background(), NewSoapClient(ts.URL, "ns"), "Export", NewMap(), "Record")
+	if err != nil {
+		t.Fatalf("CallStream failed: %v", err)
+	}
+	count := 0
+	for range records {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("streamed %d records before the error, want 1", count)
+	}
+	if err := errFn(); err == nil {
+		t.Error("expected the decode error from errFn")
+	}
+}
+
+func TestCallStream_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><ExportResponse>`)
+		for i := 0; i < 10; i++ {
+			fmt.Fprint(w, `<Record/>`)
+		}
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	records, errFn, err := CallStream[struct{}](ctx, NewSoapClient(ts.URL, "ns"), "Export", NewMap(), "Record")
+	if err != nil {
+		t.Fatalf("CallStream failed: %v", err)
+	}
+	<-records
+	cancel()
+	for range records {
+		// Drained until the goroutine notices the cancellation
+	}
+	if err := errFn(); !errors.Is(err, context.Canceled) {
+		t.Errorf("errFn after cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestCallStream_Fault(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault><faultcode>soap:Server</faultcode><faultstring>Busy</faultstring></soap:Fault></soap:Body></soap:Envelope>`)
+	}))
+	defer ts.Close()
+
+	_, _, err := CallStream[struct{}](context.Background(), NewSoapClient(ts.URL, "ns"), "Export", NewMap(), "Record")
+	var fault *SoapFault
+	if !errors.As(err, &fault) || fault.Message != "Busy" {
+		t.Errorf("CallStream on fault = %v, want *SoapFault Busy", err)
+	}
+}
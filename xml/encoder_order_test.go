@@ -2,8 +2,11 @@ package xml
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestEncoder_OrderedMap_PreservesOrder(t *testing.T) {
@@ -102,3 +105,220 @@ func TestEncoder_Marshal_OrderedMap(t *testing.T) {
 		t.Errorf("Marshal(OrderedMap) mismatch.\nGot:  %s\nWant: %s", s, expected)
 	}
 }
+
+func TestEncoder_TypeAwareValues(t *testing.T) {
+	root := NewMap()
+	root.Put("@at", time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC))
+	root.Put("Issued", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	root.Put("Amount", json.Number("1000.50"))
+	root.Put("Blob", []byte("hi"))
+
+	doc := NewMap()
+	doc.Put("Doc", root)
+
+	s, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	expected := `<Doc at="2025-01-01T10:30:00Z"><Issued>2025-01-01T00:00:00Z</Issued><Amount>1000.50</Amount><Blob>aGk=</Blob></Doc>`
+	if s != expected {
+		t.Errorf("Type-aware encoding mismatch.\nGot:  %s\nWant: %s", s, expected)
+	}
+
+	// Configurable layouts / byte encodings
+	s, err = Marshal(doc, WithTimeLayout("2006-01-02"), WithBytesEncoder(hex.EncodeToString))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(s, "<Issued>2025-01-01</Issued>") || !strings.Contains(s, "<Blob>6869</Blob>") {
+		t.Errorf("Custom formats not applied: %s", s)
+	}
+}
+
+func TestEncoder_BooleanFormat(t *testing.T) {
+	root := NewMap()
+	root.Put("@active", true)
+	root.Put("Paid", false)
+	root.Put("Flags", []any{true, false})
+	root.Put("Text", "true")
+
+	doc := NewMap()
+	doc.Put("Doc", root)
+
+	s, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `<Doc active="true"><Paid>false</Paid><Flags>true</Flags><Flags>false</Flags><Text>true</Text></Doc>`; s != want {
+		t.Errorf("default booleans = %s, want %s", s, want)
+	}
+
+	s, err = Marshal(doc, WithBooleanFormat("1", "0"))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	// Only bool values change; the string "true" is written as is
+	if want := `<Doc active="1"><Paid>0</Paid><Flags>1</Flags><Flags>0</Flags><Text>true</Text></Doc>`; s != want {
+		t.Errorf("WithBooleanFormat(1, 0) = %s, want %s", s, want)
+	}
+}
+
+func TestEncoder_HTMLOutput(t *testing.T) {
+	html := `<div class="a"><p>One<br>Two</p><input type="checkbox" checked disabled><script>if (a < b && c) { x = "<p>"; }</script><style>a > b { color: red }</style></div>`
+	m, err := MapXML(strings.NewReader(html), EnableExperimental())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	m.Set("div/input/@readonly", false)
+
+	s, err := Marshal(m, WithHTMLOutput())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<div class="a"><p>OneTwo<br></p><input type="checkbox" checked disabled><script>if (a < b && c) { x = "<p>"; }</script><style>a > b { color: red }</style></div>`
+	if s != want {
+		t.Errorf("WithHTMLOutput:\ngot  %s\nwant %s", s, want)
+	}
+
+	// XML output is unchanged
+	s, err = Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	for _, frag := range []string{"<br></br>", `checked="checked"`, `readonly="false"`, "if (a &lt; b &amp;&amp; c)"} {
+		if !strings.Contains(s, frag) {
+			t.Errorf("XML output missing %q: %s", frag, s)
+		}
+	}
+}
+
+func TestEncoder_AttributeWhitespaceEscaping(t *testing.T) {
+	root := NewMap()
+	root.Put("@note", "line1\nline2\tend\r")
+
+	doc := NewMap()
+	doc.Put("Item", root)
+
+	s, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	expected := `<Item note="line1&#10;line2&#9;end&#13;"></Item>`
+	if s != expected {
+		t.Errorf("Attribute escaping mismatch.\nGot:  %s\nWant: %s", s, expected)
+	}
+
+	// The references survive a parse round-trip
+	m, err := MapXML(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	if got := m.String("Item/@note"); got != "line1\nline2\tend\r" {
+		t.Errorf("Round-tripped attribute = %q", got)
+	}
+}
+
+func TestEncoder_OmitEmptyAttributes(t *testing.T) {
+	root := NewMap()
+	root.Put("@id", "7")
+	root.Put("@note", "")
+	root.Put("#text", "x")
+
+	doc := NewMap()
+	doc.Put("Item", root)
+
+	s, _ := Marshal(doc)
+	if s != `<Item id="7" note="">x</Item>` {
+		t.Errorf("default should keep empty attributes, got %s", s)
+	}
+
+	s, _ = Marshal(doc, WithOmitEmptyAttributes())
+	if s != `<Item id="7">x</Item>` {
+		t.Errorf("WithOmitEmptyAttributes output = %s", s)
+	}
+
+	// Legacy maps too
+	s, _ = Marshal(map[string]any{"Item": map[string]any{"@a": "", "@b": "1"}}, WithOmitEmptyAttributes())
+	if s != `<Item b="1"></Item>` {
+		t.Errorf("WithOmitEmptyAttributes (map) output = %s", s)
+	}
+}
+
+func TestEncoder_WithBOM(t *testing.T) {
+	m := NewMap()
+	m.Set("root/item", "x")
+	bom := []byte{0xEF, 0xBB, 0xBF}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithBOM())
+	if err := enc.Encode(m); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if err := enc.Encode(m); err != nil {
+		t.Fatalf("second Encode failed: %v", err)
+	}
+	out := buf.Bytes()
+	if !bytes.HasPrefix(out, bom) {
+		t.Errorf("output should start with EF BB BF, got % X", out[:3])
+	}
+	if n := bytes.Count(out, bom); n != 1 {
+		t.Errorf("BOM written %d times, want 1", n)
+	}
+
+	plain, _ := Marshal(m)
+	if bytes.HasPrefix([]byte(plain), bom) {
+		t.Error("BOM written without WithBOM")
+	}
+
+	// Our own parser reads BOM-prefixed output back
+	withBOM, _ := Marshal(m, WithBOM(), WithPrettyPrint())
+	back, err := MapXML(strings.NewReader(withBOM))
+	if err != nil || back.String("root/item") != "x" {
+		t.Errorf("re-parsing BOM output: %v, %v", err, back)
+	}
+}
+
+func TestEncoder_WithTrailingNewline(t *testing.T) {
+	m := NewMap()
+	m.Set("root/item", "x")
+
+	out, _ := Marshal(m, WithTrailingNewline())
+	if out != "<root><item>x</item></root>\n" {
+		t.Errorf("output = %q, want exactly one trailing newline", out)
+	}
+	pretty, _ := Marshal(m, WithTrailingNewline(), WithPrettyPrint())
+	if !strings.HasSuffix(pretty, "</root>\n") || strings.HasSuffix(pretty, "\n\n") {
+		t.Errorf("pretty output = %q, want exactly one trailing newline", pretty)
+	}
+	if plain, _ := Marshal(m); strings.HasSuffix(plain, "\n") {
+		t.Errorf("newline written without the option: %q", plain)
+	}
+}
+
+func TestEncoder_NamespaceDeclarationOrder(t *testing.T) {
+	root := NewMap()
+	root.Put("@id", "7")
+	root.Put("cbc:ID", "A1")
+	doc := NewMap()
+	doc.Put("Invoice", root)
+	ns := RegisterNamespace("cbc", "urn:cbc")
+
+	tests := []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{ns}, `<Invoice xmlns:cbc="urn:cbc" id="7"><cbc:ID>A1</cbc:ID></Invoice>`},
+		{[]Option{ns, WithNamespacesAfterAttributes()}, `<Invoice id="7" xmlns:cbc="urn:cbc"><cbc:ID>A1</cbc:ID></Invoice>`},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(doc, tt.opts...)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Marshal =\n%s\nwant\n%s", got, tt.want)
+		}
+	}
+}
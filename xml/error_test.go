@@ -37,3 +37,23 @@ func TestXMLError(t *testing.T) {
 	}
 	t.Logf("Got expected error: %v", syntaxErr)
 }
+
+func TestIsWellFormed(t *testing.T) {
+	if err := IsWellFormed(strings.NewReader(`<root><a x="1">ok</a><b/></root>`)); err != nil {
+		t.Errorf("IsWellFormed(valid) = %v, want nil", err)
+	}
+
+	err := IsWellFormed(strings.NewReader("<root>\n<open>oops"))
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("IsWellFormed(malformed) = %T %v, want *SyntaxError", err, err)
+	}
+	if syntaxErr.Line != 2 {
+		t.Errorf("Line = %d, want 2", syntaxErr.Line)
+	}
+
+	// Mismatched tags are caught too, not just truncation
+	if err := IsWellFormed(strings.NewReader(`<root><a></b></root>`)); err == nil {
+		t.Error("IsWellFormed(mismatched) = nil, want error")
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"go/format"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // ============================================================================
@@ -48,21 +51,269 @@ func ReaderToJSON(r io.Reader) ([]byte, error) {
 	return m.MarshalJSON()
 }
 
-// ToCSV writes a list of nodes in CSV format.
+// JSONToXML converts a JSON document to XML, the reverse of ToJSON: object
+// key order is kept, arrays become repeated elements, "@"-prefixed keys
+// attributes and "#text" character data, as the Encoder writes them. The
+// JSON value is wrapped in a rootName element; with rootName "" it must be
+// an object with a single element key (as ToJSON produces). The items of a
+// top-level array are written as <item> children of the root.
+//
+//	xml.JSONToXML(strings.NewReader(`{"id":7,"tag":["a","b"]}`), "order")
+//	// <order><id>7</id><tag>a</tag><tag>b</tag></order>
+func JSONToXML(r io.Reader, rootName string) (string, error) {
+	m, err := decodeJSONDocument(r, rootName)
+	if err != nil {
+		return "", err
+	}
+	return Marshal(m)
+}
+
+// decodeJSONDocument decodes JSON into the map JSONToXML encodes.
+func decodeJSONDocument(r io.Reader, rootName string) (*OrderedMap, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("json: %w", err)
+	}
+	if rootName == "" {
+		m, ok := v.(*OrderedMap)
+		if !ok {
+			return nil, fmt.Errorf("json: a %T document needs a root element name", v)
+		}
+		return m, nil
+	}
+	if list, ok := v.([]any); ok {
+		v = NewMap().Set("item", list)
+	}
+	return NewMap().Set(rootName, v), nil
+}
+
+// decodeJSONValue reads the next JSON value, decoding objects into
+// *OrderedMap so their key order survives (encoding/json maps do not).
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			m := NewMap()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				val, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				m.Put(keyTok.(string), val)
+			}
+			_, err := dec.Token() // '}'
+			return m, err
+		case '[':
+			list := []any{}
+			for dec.More() {
+				val, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, val)
+			}
+			_, err := dec.Token() // ']'
+			return list, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	}
+	return tok, nil
+}
+
+// ============================================================================
+// YAML EXPORT
+// ============================================================================
+
+type yamlConfig struct {
+	attrPrefix string
+}
+
+// YAMLOption configures ToYAML.
+type YAMLOption func(*yamlConfig)
+
+// WithYAMLAttrPrefix replaces the "@" prefix of attribute keys in the YAML
+// output (e.g. "_" or "" for none).
+func WithYAMLAttrPrefix(prefix string) YAMLOption {
+	return func(c *yamlConfig) { c.attrPrefix = prefix }
+}
+
+// ToYAML renders a parsed document (or any map, list or scalar) as block
+// YAML. *OrderedMap keys keep their order (plain maps are sorted),
+// repeated elements become sequences and strings are quoted whenever YAML
+// would otherwise read them as something else ("123", "true", "@id").
+func ToYAML(data any, opts ...YAMLOption) (string, error) {
+	cfg := &yamlConfig{attrPrefix: "@"}
+	for _, o := range opts {
+		o(cfg)
+	}
+	var sb strings.Builder
+	writeYAML(&sb, data, 0, cfg)
+	return sb.String(), nil
+}
+
+// writeYAML writes v as the value of a "key:" line or sequence entry that
+// has already been written, or as the whole document at indent 0.
+func writeYAML(sb *strings.Builder, v any, indent int, cfg *yamlConfig) {
+	pad := strings.Repeat("  ", indent)
+	switch t := v.(type) {
+	case *OrderedMap:
+		writeYAMLMap(sb, t.Keys(), t.Get, indent, cfg)
+	case map[string]any:
+		writeYAMLMap(sb, sortedKeys(t), func(k string) any { return t[k] }, indent, cfg)
+	case []*OrderedMap:
+		items := make([]any, len(t))
+		for i, item := range t {
+			items[i] = item
+		}
+		writeYAML(sb, items, indent, cfg)
+	case []any:
+		if len(t) == 0 {
+			sb.WriteString("[]\n")
+			return
+		}
+		for _, item := range t {
+			sb.WriteString(pad + "-")
+			if isYAMLCollection(item) {
+				// Nested block: the first line goes after the "- "
+				var nested strings.Builder
+				writeYAML(&nested, item, indent+1, cfg)
+				sb.WriteString(" " + strings.TrimLeft(nested.String(), " "))
+				continue
+			}
+			sb.WriteString(" ")
+			writeYAML(sb, item, indent+1, cfg)
+		}
+	default:
+		sb.WriteString(yamlScalar(v) + "\n")
+	}
+}
+
+func writeYAMLMap(sb *strings.Builder, keys []string, get func(string) any, indent int, cfg *yamlConfig) {
+	if len(keys) == 0 {
+		sb.WriteString("{}\n")
+		return
+	}
+	pad := strings.Repeat("  ", indent)
+	for _, k := range keys {
+		name := k
+		if strings.HasPrefix(k, "@") {
+			name = cfg.attrPrefix + k[1:]
+		}
+		sb.WriteString(pad + yamlString(name) + ":")
+		val := get(k)
+		if isYAMLCollection(val) {
+			sb.WriteString("\n")
+			writeYAML(sb, val, indent+1, cfg)
+			continue
+		}
+		sb.WriteString(" ")
+		writeYAML(sb, val, indent+1, cfg)
+	}
+}
+
+// isYAMLCollection reports whether v is written as an indented block
+// (non-empty maps and lists).
+func isYAMLCollection(v any) bool {
+	switch t := v.(type) {
+	case *OrderedMap:
+		return t.Len() > 0
+	case map[string]any:
+		return len(t) > 0
+	case []any:
+		return len(t) > 0
+	case []*OrderedMap:
+		return len(t) > 0
+	}
+	return false
+}
+
+func yamlScalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return yamlString(t)
+	case bool, int, int64, float64, json.Number:
+		return fmt.Sprint(t)
+	}
+	return yamlString(formatValue(v, defaultConfig()))
+}
+
+// yamlString writes s plain when YAML reads it back as the same string,
+// double-quoted otherwise.
+func yamlString(s string) string {
+	if s == "" || s != strings.TrimSpace(s) ||
+		strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.IndexFunc(s, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// ToCSV writes a list of nodes in CSV format (ToDelimited with ',').
+// Nested elements are flattened into dotted columns (a <customer><name>
+// child becomes "customer.name"); an element holding only text and
+// attributes is a single column with its text. Repeated elements become
+// indexed columns ("line.0.sku"), or one column with their texts joined by
+// ";" when they hold only text. Attributes and #-keys are left out. Of the
+// CSVOptions, ToCSV honors WithFlatten (the separator) and WithMaxDepth;
+// use ToCSVWithOptions for delimiters and quoting.
 // Usage: r2xml csv data.xml --path="orders/order"
-func ToCSV(w io.Writer, nodes []*OrderedMap) error {
+func ToCSV(w io.Writer, nodes []*OrderedMap, opts ...CSVOption) error {
+	cfg := &csvConfig{flattenSep: "."}
+	for _, o := range opts {
+		o(cfg)
+	}
+	return writeDelimited(w, nodes, ',', cfg)
+}
+
+// ToDelimited is ToCSV with another field separator, e.g. '\t' for TSV. A
+// value is quoted when it contains delim, a newline or a double quote.
+//
+// Usage:
+//
+//	err := xml.ToDelimited(os.Stdout, list, '\t')
+func ToDelimited(w io.Writer, nodes []*OrderedMap, delim rune) error {
+	return writeDelimited(w, nodes, delim, &csvConfig{flattenSep: "."})
+}
+
+// writeDelimited writes nodes as ToCSV does, separating fields with delim.
+func writeDelimited(w io.Writer, nodes []*OrderedMap, delim rune, cfg *csvConfig) error {
 	if len(nodes) == 0 {
 		return nil
 	}
 
 	// 1. Discover Headers (Unify keys from all nodes to be robust)
+	records := make([]*OrderedMap, len(nodes))
 	headerMap := make(map[string]bool)
 	var headers []string
 
-	for _, node := range nodes {
-		for _, k := range node.Keys() {
-			// Ignore attributes (@), text (#text) and cdata (#cdata) for clean CSV
-			if !headerMap[k] && !strings.HasPrefix(k, "@") && !strings.HasPrefix(k, "#") {
+	for i, node := range nodes {
+		records[i] = flattenRecord(node, cfg.flattenSep, cfg.maxDepth)
+		for _, k := range records[i].Keys() {
+			if !headerMap[k] {
 				headerMap[k] = true
 				headers = append(headers, k)
 			}
@@ -71,31 +322,178 @@ func ToCSV(w io.Writer, nodes []*OrderedMap) error {
 	sort.Strings(headers) // Deterministic A-Z order
 
 	// 2. Write Header
-	if _, err := fmt.Fprintln(w, strings.Join(headers, ",")); err != nil {
+	if err := writeDelimitedRow(w, headers, delim); err != nil {
 		return err
 	}
 
 	// 3. Write Rows
-	for _, node := range nodes {
-		var row []string
-		for _, h := range headers {
-			val := node.String(h)
-
-			// Escape double quotes for standard CSV (RFC 4180)
-			val = strings.ReplaceAll(val, "\"", "\"\"")
+	for _, record := range records {
+		if err := writeRecord(w, record, headers, delim); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			// If it contains commas, newlines or quotes, wrap in quotes
-			if strings.ContainsAny(val, ",\n\"") {
-				val = fmt.Sprintf("\"%s\"", val)
+// flattenRecord returns the columns of a CSV record: the element children
+// of node, with nested elements expanded into keys joined by sep, at most
+// maxDepth levels deep (0 = no limit; deeper elements are skipped). An
+// element with only text and attributes is kept as its text. With an empty
+// sep nested elements are skipped.
+//
+// A repeated element whose occurrences only hold text becomes one column
+// with the texts joined by ";". When an occurrence has child elements, each
+// one gets its own indexed columns ("line.0.sku", "line.1.sku").
+func flattenRecord(node *OrderedMap, sep string, maxDepth int) *OrderedMap {
+	flat := NewMap()
+	var walk func(prefix string, m *OrderedMap, depth int)
+	var put func(key string, v any, depth int)
+	put = func(key string, v any, depth int) {
+		switch v := v.(type) {
+		case *OrderedMap:
+			if !hasElementKeys(v) {
+				flat.Put(key, filterText(v))
+			} else if sep != "" && (maxDepth == 0 || depth < maxDepth) {
+				walk(key+sep, v, depth+1)
+			}
+		case []*OrderedMap:
+			items := make([]any, len(v))
+			for i, item := range v {
+				items[i] = item
+			}
+			put(key, items, depth)
+		case []any:
+			nested := false
+			for _, item := range v {
+				if m, ok := item.(*OrderedMap); ok && hasElementKeys(m) {
+					nested = true
+					break
+				}
+			}
+			if !nested {
+				texts := make([]string, len(v))
+				for i, item := range v {
+					texts[i] = csvField(item)
+					if m, ok := item.(*OrderedMap); ok {
+						texts[i] = filterText(m)
+					}
+				}
+				flat.Put(key, strings.Join(texts, ";"))
+			} else if sep != "" && (maxDepth == 0 || depth < maxDepth) {
+				for i, item := range v {
+					put(key+sep+strconv.Itoa(i), item, depth)
+				}
+			}
+		default:
+			flat.Put(key, v)
+		}
+	}
+	walk = func(prefix string, m *OrderedMap, depth int) {
+		m.ForEach(func(k string, v any) bool {
+			if strings.HasPrefix(k, "@") || strings.HasPrefix(k, "#") {
+				return true // Ignore attributes (@), text (#text) and cdata (#cdata)
 			}
+			put(prefix+k, v, depth)
+			return true
+		})
+	}
+	walk("", node, 0)
+	return flat
+}
+
+// hasElementKeys reports whether m has child elements (keys other than
+// attributes and #-keys).
+func hasElementKeys(m *OrderedMap) bool {
+	for _, k := range m.Keys() {
+		if !strings.HasPrefix(k, "@") && !strings.HasPrefix(k, "#") {
+			return true
+		}
+	}
+	return false
+}
 
-			row = append(row, val)
+// csvField returns the text of a flattened record value.
+func csvField(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// writeRecord writes the headers columns of a flattened record as one
+// ToDelimited row.
+func writeRecord(w io.Writer, record *OrderedMap, headers []string, delim rune) error {
+	row := make([]string, len(headers))
+	for i, h := range headers {
+		row[i] = csvField(record.Get(h))
+	}
+	return writeDelimitedRow(w, row, delim)
+}
+
+// writeDelimitedRow writes one ToDelimited line.
+func writeDelimitedRow(w io.Writer, fields []string, delim rune) error {
+	row := make([]string, len(fields))
+	for i, val := range fields {
+		// Quote (RFC 4180) values that contain the delimiter, newlines or
+		// quotes, doubling the quotes
+		if strings.ContainsRune(val, delim) || strings.ContainsAny(val, "\r\n\"") {
+			val = `"` + strings.ReplaceAll(val, `"`, `""`) + `"`
 		}
-		if _, err := fmt.Fprintln(w, strings.Join(row, ",")); err != nil {
+		row[i] = val
+	}
+	_, err := fmt.Fprintln(w, strings.Join(row, string(delim)))
+	return err
+}
+
+// StreamToCSV is ToCSV over a document of any size: every recordTag element
+// of r (matched by local name, as NewStream does) is decoded and written as
+// a row as soon as it is read, so memory stays flat. Records are flattened
+// as by ToCSV ("customer.name"). columns selects the columns and their
+// order; when empty, the headers are the first record's columns, sorted as
+// ToCSV does. Without records and columns nothing is written.
+//
+// Usage:
+//
+//	err := xml.StreamToCSV(os.Stdout, file, "order", []string{"id", "total"})
+func StreamToCSV(w io.Writer, r io.Reader, recordTag string, columns []string) error {
+	return streamDelimited(w, NewStream[*OrderedMap](r, recordTag), columns, ',')
+}
+
+// streamDelimited is StreamToCSV over the records of stream, with another
+// field separator (see ToDelimited).
+func streamDelimited(w io.Writer, stream *Stream[*OrderedMap], columns []string, delim rune) error {
+	var headers []string
+	if len(columns) > 0 {
+		headers = columns
+		if err := writeDelimitedRow(w, headers, delim); err != nil {
+			return err
+		}
+	}
+	for {
+		node, err := stream.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		record := flattenRecord(node, ".", 0)
+		if headers == nil {
+			headers = append([]string{}, record.Keys()...)
+			sort.Strings(headers)
+			if err := writeDelimitedRow(w, headers, delim); err != nil {
+				return err
+			}
+		}
+		if err := writeRecord(w, record, headers, delim); err != nil {
 			return err
 		}
 	}
-	return nil
 }
 
 // ============================================================================
@@ -105,7 +503,8 @@ func ToCSV(w io.Writer, nodes []*OrderedMap) error {
 type csvConfig struct {
 	delimiter  rune
 	quoteAll   bool
-	flattenSep string // "" = skip nested objects (ToCSV's current behavior)
+	flattenSep string // "" = skip nested objects
+	maxDepth   int    // Nested levels flattened, 0 = no limit
 }
 
 // CSVOption configures ToCSVWithOptions.
@@ -122,19 +521,28 @@ func WithQuoteAll(b bool) CSVOption {
 	return func(c *csvConfig) { c.quoteAll = b }
 }
 
-// WithFlatten flattens one level of nested *OrderedMap children into
-// columns named "parent<sep>child", instead of skipping them (ToCSV's
-// default behavior).
+// WithFlatten flattens nested *OrderedMap children into columns named
+// "parent<sep>child", instead of skipping them (ToCSVWithOptions' default
+// behavior). ToCSVWithOptions flattens one level unless WithMaxDepth is set;
+// for ToCSV it replaces the default "." separator.
 func WithFlatten(sep string) CSVOption {
 	return func(c *csvConfig) { c.flattenSep = sep }
 }
 
+// WithMaxDepth limits flattening to n levels of nested elements below each
+// record (0 = no limit); deeper elements are left out. With n = 1,
+// <customer><address><city> yields no column, <customer><name> yields
+// "customer.name".
+func WithMaxDepth(n int) CSVOption {
+	return func(c *csvConfig) { c.maxDepth = n }
+}
+
 // ToCSVWithOptions is ToCSV with configurable delimiter, quoting and
 // nested-object flattening, built on encoding/csv (correct RFC 4180
 // quoting/escaping, including embedded CRLF) instead of ToCSV's hand-rolled
 // version.
 func ToCSVWithOptions(w io.Writer, nodes []*OrderedMap, opts ...CSVOption) error {
-	cfg := &csvConfig{delimiter: ','}
+	cfg := &csvConfig{delimiter: ',', maxDepth: 1}
 	for _, o := range opts {
 		o(cfg)
 	}
@@ -143,32 +551,16 @@ func ToCSVWithOptions(w io.Writer, nodes []*OrderedMap, opts ...CSVOption) error
 	}
 
 	// 1. Discover Headers
+	records := make([]*OrderedMap, len(nodes))
 	headerMap := make(map[string]bool)
 	var headers []string
-	addHeader := func(h string) {
-		if !headerMap[h] {
-			headerMap[h] = true
-			headers = append(headers, h)
-		}
-	}
-	for _, node := range nodes {
-		for _, k := range node.Keys() {
-			if strings.HasPrefix(k, "@") || strings.HasPrefix(k, "#") {
-				continue
-			}
-			if child, ok := node.Get(k).(*OrderedMap); ok {
-				if cfg.flattenSep == "" {
-					continue // nested objects skipped unless WithFlatten is set
-				}
-				for _, ck := range child.Keys() {
-					if strings.HasPrefix(ck, "@") || strings.HasPrefix(ck, "#") {
-						continue
-					}
-					addHeader(k + cfg.flattenSep + ck)
-				}
-				continue
+	for i, node := range nodes {
+		records[i] = flattenRecord(node, cfg.flattenSep, cfg.maxDepth)
+		for _, k := range records[i].Keys() {
+			if !headerMap[k] {
+				headerMap[k] = true
+				headers = append(headers, k)
 			}
-			addHeader(k)
 		}
 	}
 	sort.Strings(headers)
@@ -192,15 +584,10 @@ func ToCSVWithOptions(w io.Writer, nodes []*OrderedMap, opts ...CSVOption) error
 		return err
 	}
 
-	for _, node := range nodes {
+	for _, record := range records {
 		row := make([]string, len(headers))
 		for i, h := range headers {
-			if cfg.flattenSep != "" && strings.Contains(h, cfg.flattenSep) {
-				parts := strings.SplitN(h, cfg.flattenSep, 2)
-				row[i] = node.String(parts[0] + "/" + parts[1])
-			} else {
-				row[i] = node.String(h)
-			}
+			row[i] = csvField(record.Get(h))
 		}
 		if err := writeRow(row); err != nil {
 			return err
@@ -213,3 +600,209 @@ func ToCSVWithOptions(w io.Writer, nodes []*OrderedMap, opts ...CSVOption) error
 	}
 	return nil
 }
+
+// ============================================================================
+// STRUCT SKELETON (encoding/xml code generation)
+// ============================================================================
+
+type skeletonField struct {
+	name   string
+	goType string
+	tag    string
+}
+
+type skeletonType struct {
+	name    string
+	xmlName string // Root only: value of the XMLName tag
+	fields  []*skeletonField
+	byKey   map[string]*skeletonField // OrderedMap key -> field (merges repeated elements)
+	names   map[string]bool
+}
+
+type skeletonGen struct {
+	types  []*skeletonType
+	byName map[string]*skeletonType
+}
+
+// GenerateStructSkeleton emits Go source for encoding/xml structs matching
+// a parsed document, as a starting point for moving a sample document to
+// strict typing. om is a document as returned by MapXML (a single root
+// element key); typeName names the root struct and prefixes the nested ones
+// (root "Order" + child "item" -> "OrderItem").
+//
+// Attributes become `xml:"name,attr"` fields, "#text"/"#cdata" become a
+// Value field with ",chardata"/",cdata", repeated elements become slices and
+// xmlns declarations are dropped. Scalar types follow the parsed values:
+// string unless type inference produced an int, float64 or bool.
+func GenerateStructSkeleton(om *OrderedMap, typeName string) string {
+	g := &skeletonGen{byName: make(map[string]*skeletonType)}
+	root := g.typeFor(typeName)
+
+	if keys := om.Keys(); len(keys) == 1 && isPlainKey(keys[0]) {
+		rootTag := keys[0]
+		root.xmlName = localName(rootTag)
+		switch v := om.Get(rootTag).(type) {
+		case *OrderedMap:
+			if ns := v.String("@xmlns"); ns != "" {
+				root.xmlName = ns + " " + root.xmlName
+			}
+			g.collect(root, v)
+		default:
+			root.addField("#text", "Value", skeletonScalarType(v), ",chardata")
+		}
+	} else {
+		g.collect(root, om)
+	}
+
+	var sb strings.Builder
+	for i, t := range g.types {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "type %s struct {\n", t.name)
+		if t.xmlName != "" {
+			fmt.Fprintf(&sb, "\tXMLName xml.Name `xml:\"%s\"`\n", t.xmlName)
+		}
+		for _, f := range t.fields {
+			fmt.Fprintf(&sb, "\t%s %s `xml:\"%s\"`\n", f.name, f.goType, f.tag)
+		}
+		sb.WriteString("}\n")
+	}
+
+	src := sb.String()
+	if formatted, err := format.Source([]byte(src)); err == nil {
+		return string(formatted)
+	}
+	return src
+}
+
+func (g *skeletonGen) typeFor(name string) *skeletonType {
+	if t, ok := g.byName[name]; ok {
+		return t
+	}
+	t := &skeletonType{
+		name:  name,
+		byKey: make(map[string]*skeletonField),
+		names: map[string]bool{"XMLName": true},
+	}
+	g.byName[name] = t
+	g.types = append(g.types, t)
+	return t
+}
+
+func (g *skeletonGen) collect(t *skeletonType, m *OrderedMap) {
+	m.ForEach(func(key string, val any) bool {
+		switch {
+		case key == "@xmlns" || strings.HasPrefix(key, "@xmlns:"):
+			// Namespace declarations are not data
+		case strings.HasPrefix(key, "@"):
+			local := localName(key[1:])
+			t.addField(key, exportedName(local), skeletonScalarType(val), local+",attr")
+		case key == "#text":
+			t.addField(key, "Value", skeletonScalarType(val), ",chardata")
+		case key == "#cdata":
+			t.addField(key, "Value", "string", ",cdata")
+		case key == "#comment":
+			t.addField(key, "Comment", "string", ",comment")
+		case strings.HasPrefix(key, "#"):
+			// #seq and other synthetic keys have no struct equivalent
+		default:
+			g.collectElement(t, key, val)
+		}
+		return true
+	})
+}
+
+func (g *skeletonGen) collectElement(t *skeletonType, key string, val any) {
+	local := localName(key)
+	items, isList := val.([]any)
+	if !isList {
+		items = []any{val}
+	}
+
+	goType := ""
+	var child *skeletonType
+	for _, item := range items {
+		if m, ok := item.(*OrderedMap); ok {
+			if child == nil {
+				child = g.typeFor(t.name + exportedName(local))
+			}
+			g.collect(child, m)
+		}
+	}
+	if child != nil {
+		goType = child.name
+		// Text-only occurrences mixed with complex ones land in chardata
+		for _, item := range items {
+			if _, ok := item.(*OrderedMap); !ok {
+				child.addField("#text", "Value", "string", ",chardata")
+			}
+		}
+	} else {
+		goType = skeletonScalarType(items[0])
+	}
+	if isList {
+		goType = "[]" + goType
+	}
+	t.addField(key, exportedName(local), goType, local)
+}
+
+func (t *skeletonType) addField(key, name, goType, tag string) {
+	if f, ok := t.byKey[key]; ok {
+		// Repeated element seen again (e.g. while merging list items):
+		// a list anywhere wins over a single occurrence.
+		if strings.HasPrefix(goType, "[]") && !strings.HasPrefix(f.goType, "[]") {
+			f.goType = goType
+		}
+		return
+	}
+	if t.names[name] {
+		if strings.HasSuffix(tag, ",attr") {
+			name += "Attr"
+		}
+		for base, i := name, 2; t.names[name]; i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+	}
+	t.names[name] = true
+	f := &skeletonField{name: name, goType: goType, tag: tag}
+	t.byKey[key] = f
+	t.fields = append(t.fields, f)
+}
+
+func skeletonScalarType(v any) string {
+	switch v.(type) {
+	case int:
+		return "int"
+	case int64:
+		return "int64"
+	case float64:
+		return "float64"
+	case bool:
+		return "bool"
+	}
+	return "string"
+}
+
+// exportedName turns an XML name into an exported Go identifier
+// ("unit-price" -> "UnitPrice").
+func exportedName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	out := sb.String()
+	if out == "" || unicode.IsDigit(rune(out[0])) {
+		out = "X" + out
+	}
+	return out
+}
@@ -2,6 +2,8 @@ package xml
 
 import (
 	"bytes"
+	"go/parser"
+	"go/token"
 	"strings"
 	"testing"
 )
@@ -74,6 +76,39 @@ func TestToCSV_Escaping(t *testing.T) {
 	}
 }
 
+func TestToDelimited_Escaping(t *testing.T) {
+	tests := []struct {
+		name  string
+		delim rune
+		value string
+		want  string
+	}{
+		{"csv comma", ',', "a,b", `"a,b"`},
+		{"tsv comma", '\t', "a,b", `a,b`},
+		{"tsv tab", '\t', "a\tb", "\"a\tb\""},
+		{"tsv quote", '\t', `say "hi"`, `"say ""hi"""`},
+		{"tsv newline", '\t', "a\nb", "\"a\nb\""},
+		{"tsv carriage return", '\t', "a\rb", "\"a\rb\""},
+		{"semicolon", ';', "a;b,c", `"a;b,c"`},
+		{"plain", '\t', "plain", "plain"},
+	}
+	for _, tt := range tests {
+		node := NewMap()
+		node.Put("id", "1")
+		node.Put("v", tt.value)
+
+		var buf bytes.Buffer
+		if err := ToDelimited(&buf, []*OrderedMap{node}, tt.delim); err != nil {
+			t.Fatalf("%s: ToDelimited failed: %v", tt.name, err)
+		}
+		d := string(tt.delim)
+		want := "id" + d + "v\n1" + d + tt.want + "\n"
+		if buf.String() != want {
+			t.Errorf("%s: got %q, want %q", tt.name, buf.String(), want)
+		}
+	}
+}
+
 func TestToCSV_Empty(t *testing.T) {
 	var buf bytes.Buffer
 	if err := ToCSV(&buf, []*OrderedMap{}); err != nil {
@@ -140,6 +175,86 @@ func TestToJSON_Fallback(t *testing.T) {
 	}
 }
 
+func TestJSONToXML(t *testing.T) {
+	tests := []struct {
+		name, json, root, want string
+	}{
+		{"key order", `{"z":1,"a":"x","m":true}`, "r", `<r><z>1</z><a>x</a><m>true</m></r>`},
+		{"arrays repeat the tag", `{"tag":["a","b"],"n":1.50}`, "order", `<order><tag>a</tag><tag>b</tag><n>1.50</n></order>`},
+		{"attributes and text", `{"price":{"@currency":"COP","#text":"1000"},"note":null}`, "item", `<item><price currency="COP">1000</price><note></note></item>`},
+		{"no root name", `{"invoice":{"@id":"7","line":[{"sku":"A"},{"sku":"B"}]}}`, "", `<invoice id="7"><line><sku>A</sku></line><line><sku>B</sku></line></invoice>`},
+		{"top-level array", `[1,2]`, "list", `<list><item>1</item><item>2</item></list>`},
+		{"escaping", `{"q":"a<b & \"c\""}`, "r", `<r><q>a&lt;b &amp; &#34;c&#34;</q></r>`},
+	}
+	for _, tt := range tests {
+		got, err := JSONToXML(strings.NewReader(tt.json), tt.root)
+		if err != nil {
+			t.Errorf("%s: JSONToXML error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: JSONToXML = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Round trip through ToJSON
+	in := `<order id="1"><b>2</b><a>1</a></order>`
+	js, _ := ToJSON(strings.NewReader(in))
+	if back, err := JSONToXML(strings.NewReader(js), ""); err != nil || back != in {
+		t.Errorf("round trip = %s (%v), want %s", back, err, in)
+	}
+
+	for _, bad := range []struct{ json, root string }{
+		{`{"a":`, "r"},
+		{`[1]`, ""},
+		{`{"a":1,"b":2}`, ""},
+	} {
+		if _, err := JSONToXML(strings.NewReader(bad.json), bad.root); err == nil {
+			t.Errorf("JSONToXML(%s, %q) should fail", bad.json, bad.root)
+		}
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<order id="7"><status>new: pending</status><total>10.50</total><paid>true</paid><note/><item><sku>A</sku><tag>x</tag><tag>y</tag></item><item><sku>B</sku></item></order>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	got, err := ToYAML(m)
+	if err != nil {
+		t.Fatalf("ToYAML error: %v", err)
+	}
+	want := `order:
+  "@id": "7"
+  status: "new: pending"
+  total: "10.50"
+  paid: "true"
+  note: {}
+  item:
+    - sku: A
+      tag:
+        - x
+        - "y"
+    - sku: B
+`
+	if got != want {
+		t.Errorf("ToYAML =\n%s\nwant\n%s", got, want)
+	}
+
+	got, _ = ToYAML(m.GetNode("order"), WithYAMLAttrPrefix("_"))
+	if !strings.HasPrefix(got, "_id: \"7\"\n") {
+		t.Errorf("ToYAML with attribute prefix =\n%s", got)
+	}
+
+	// Native values and nested lists
+	got, _ = ToYAML(map[string]any{"b": []any{[]any{1, 2}, nil}, "a": 1.5, "c": "multi\nline"})
+	want = "a: 1.5\nb:\n  - - 1\n    - 2\n  - null\nc: \"multi\\nline\"\n"
+	if got != want {
+		t.Errorf("ToYAML(map) = %q, want %q", got, want)
+	}
+}
+
 func TestToCSVWithOptions_Delimiter(t *testing.T) {
 	item := NewMap()
 	item.Put("id", "1")
@@ -178,6 +293,65 @@ func TestToCSVWithOptions_QuoteAll(t *testing.T) {
 	}
 }
 
+func TestToCSV_FlattensNested(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<orders>
+		<order id="1"><total currency="USD">10</total><customer><name>Ana</name><address><city>Bogota</city></address></customer></order>
+		<order id="2"><total currency="USD">20</total><customer><name>Luis</name></customer></order>
+	</orders>`), ForceArray("order"))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	list := m.List("orders/order")
+
+	tests := []struct {
+		name string
+		opts []CSVOption
+		want string
+	}{
+		{"unlimited", nil, "customer.address.city,customer.name,total\nBogota,Ana,10\n,Luis,20\n"},
+		{"max depth 1", []CSVOption{WithMaxDepth(1)}, "customer.name,total\nAna,10\nLuis,20\n"},
+		{"separator", []CSVOption{WithFlatten("_"), WithMaxDepth(1)}, "customer_name,total\nAna,10\nLuis,20\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := ToCSV(&buf, list, tt.opts...); err != nil {
+			t.Fatalf("%s: ToCSV failed: %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestToCSV_FlattensLists(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<orders>
+		<order><id>1</id><line><sku>A</sku><qty>2</qty></line><line><sku>B</sku><qty>1</qty></line><tag>a</tag><tag>b</tag></order>
+		<order><id>2</id><line><sku>C</sku><qty>5</qty></line><tag>c</tag></order>
+	</orders>`), ForceArray("order", "line", "tag"))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	list := m.List("orders/order")
+
+	tests := []struct {
+		name string
+		opts []CSVOption
+		want string
+	}{
+		{"indexed", nil, "id,line.0.qty,line.0.sku,line.1.qty,line.1.sku,tag\n1,2,A,1,B,a;b\n2,5,C,,,c\n"},
+		{"no flatten", []CSVOption{WithFlatten("")}, "id,tag\n1,a;b\n2,c\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := ToCSV(&buf, list, tt.opts...); err != nil {
+			t.Fatalf("%s: ToCSV failed: %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
 func TestToCSVWithOptions_Flatten(t *testing.T) {
 	addr := NewMap()
 	addr.Put("city", "Bogota")
@@ -219,3 +393,77 @@ func TestToCSVWithOptions_DefaultSkipsNested(t *testing.T) {
 		t.Errorf("without WithFlatten, nested objects should be skipped, got: %s", got)
 	}
 }
+
+func TestGenerateStructSkeleton(t *testing.T) {
+	input := `<order xmlns="urn:shop" id="7">
+		<customer vip="true">Ana</customer>
+		<item sku="a"><qty>1</qty></item>
+		<item sku="b"><qty>2</qty><unit-price>9.5</unit-price></item>
+		<note>fragile</note>
+	</order>`
+	m, err := MapXML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	src := GenerateStructSkeleton(m, "Order")
+
+	for _, want := range []string{
+		"type Order struct {",
+		"XMLName  xml.Name      `xml:\"urn:shop order\"`",
+		"Id       string        `xml:\"id,attr\"`",
+		"Customer OrderCustomer `xml:\"customer\"`",
+		"Item     []OrderItem   `xml:\"item\"`",
+		"Note     string        `xml:\"note\"`",
+		"type OrderCustomer struct {",
+		"Vip   string `xml:\"vip,attr\"`",
+		"Value string `xml:\",chardata\"`",
+		"type OrderItem struct {",
+		"UnitPrice string `xml:\"unit-price\"`",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("skeleton missing %q. Got:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "xmlns") {
+		t.Errorf("namespace declarations should not become fields. Got:\n%s", src)
+	}
+
+	// The output must be valid Go
+	if _, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+src, 0); err != nil {
+		t.Errorf("generated source does not parse: %v\n%s", err, src)
+	}
+}
+
+func TestStreamToCSV(t *testing.T) {
+	input := `<orders xmlns:g="urn:g">
+		<order id="1"><total>10</total><g:sku>A</g:sku><note>x, y</note></order>
+		<order id="2"><total>20</total><g:sku>B</g:sku><note>z</note></order>
+	</orders>`
+
+	tests := []struct {
+		name    string
+		columns []string
+		want    string
+	}{
+		{"derived headers", nil, "note,sku,total\n\"x, y\",A,10\nz,B,20\n"},
+		{"explicit columns", []string{"total", "missing", "sku"}, "total,missing,sku\n10,,A\n20,,B\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := StreamToCSV(&buf, strings.NewReader(input), "order", tt.columns); err != nil {
+			t.Fatalf("%s: StreamToCSV failed: %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, buf.String(), tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := StreamToCSV(&buf, strings.NewReader(`<orders></orders>`), "order", nil); err != nil || buf.Len() != 0 {
+		t.Errorf("no records: got %q, %v; want no output", buf.String(), err)
+	}
+	if err := StreamToCSV(&buf, strings.NewReader(`<orders><order><id>1</order>`), "order", nil); err == nil {
+		t.Error("expected an error for malformed input")
+	}
+}
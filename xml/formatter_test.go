@@ -29,3 +29,34 @@ func TestFormat_Pretty(t *testing.T) {
 		t.Errorf("Expected indentation in output: %s", out)
 	}
 }
+
+func TestFormat_PrettyMixedContent(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<doc><p>Hello<b>World</b></p><list><i>x</i></list></doc>`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	out, err := Marshal(m, WithPrettyPrint())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := "\n<doc>\n  <p>Hello<b>World</b></p>\n  <list>\n    <i>x</i>\n  </list>\n</doc>"
+	if out != want {
+		t.Errorf("mixed content was re-indented.\nGot:\n%s\nWant:\n%s", out, want)
+	}
+
+	// Same for document-order content (#seq)
+	seq := []any{NewMap(), NewMap(), NewMap()}
+	seq[0].(*OrderedMap).Put("#text", "Hello ")
+	seq[1].(*OrderedMap).Put("b", "World")
+	seq[2].(*OrderedMap).Put("#text", "!")
+	p := NewMap()
+	p.Put("#seq", seq)
+	doc := NewMap()
+	doc.Set("doc/p", p)
+
+	out, _ = Marshal(doc, WithPrettyPrint())
+	if !strings.Contains(out, "<p>Hello <b>World</b>!</p>") {
+		t.Errorf("#seq mixed content was re-indented:\n%s", out)
+	}
+}
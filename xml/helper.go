@@ -2,158 +2,395 @@ package xml
 
 import (
 	"fmt"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ============================================================================
 // QUERY ENGINE (Merged from query.go)
 // ============================================================================
 
+type queryConfig struct {
+	attrFallback bool // Resolve "key" to "@key" when there is no "key" child
+	strict       bool // Error out naming the segment that matched nothing
+	exactMatch   bool // Compare filter values without trimming whitespace
+}
+
+// QueryOption configures Query / QueryAll.
+type QueryOption func(*queryConfig)
+
+// WithAttrFallback lets a plain segment resolve to an attribute when no child
+// element matches: "user/id" finds "@id" if "user" has no <id> child.
+// Off by default to keep element and attribute lookups unambiguous.
+func WithAttrFallback() QueryOption {
+	return func(c *queryConfig) { c.attrFallback = true }
+}
+
+// WithStrictPath makes QueryAll return an error naming the segment where
+// traversal ran out of candidates ("no match at segment 'x' (2 of 4)"),
+// instead of an empty result. Query and Get then surface that error in
+// place of a plain "not found".
+func WithStrictPath() QueryOption {
+	return func(c *queryConfig) { c.strict = true }
+}
+
+// WithExactMatch compares filter values as stored: by default both sides of
+// a filter comparison are trimmed, so "book[author='Cervantes']" also
+// matches an author kept as " Cervantes " by a whitespace-preserving parse.
+func WithExactMatch() QueryOption {
+	return func(c *queryConfig) { c.exactMatch = true }
+}
+
+func newQueryConfig(opts []QueryOption) *queryConfig {
+	cfg := &queryConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	return cfg
+}
+
 // QueryAll searches the data structure for all nodes matching the provided path.
-func QueryAll(data any, path string) ([]any, error) {
+// Besides keys, a segment may be "#count" (size of each candidate) or
+// "#name" (the tag each candidate was found under, handy after "*" or a
+// "func:" segment: "invoice/items/*/#name").
+//
+// Paths are always evaluated against data, even when data is a sub-node
+// returned by an earlier query: "/x" and "x" both select data's x child,
+// "//x" finds x at any depth within data, and "a//x" finds x at any depth
+// below each a.
+//
+// "p1 | p2" is the union of several paths: each one is evaluated on its own
+// and the results are concatenated in the order the paths are written
+// (document order within each), skipping nodes already selected. A "|"
+// inside a filter ("[name='a|b']") does not split the path.
+func QueryAll(data any, path string, opts ...QueryOption) ([]any, error) {
+	results, _, err := queryAll(data, path, opts)
+	return results, err
+}
+
+// QueryAllPairs is QueryAll that also returns, for every result, the key it
+// was found under (the "#name" of the last segment), so "invoice/items/*"
+// tells which child each value was. Keys are "" for data itself (empty
+// path), "#count" and "#text" for those segments.
+//
+//	pairs, _ := xml.QueryAllPairs(m, "invoice/items/*")
+//	for _, p := range pairs { fmt.Println(p.Key, p.Value) } // box {...}, bag {...}
+func QueryAllPairs(data any, path string, opts ...QueryOption) ([]Pair, error) {
+	results, names, err := queryAll(data, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	var pairs []Pair
+	for i, v := range results {
+		pairs = append(pairs, Pair{Key: names[i], Value: v})
+	}
+	return pairs, nil
+}
+
+// queryAll implements QueryAll, also returning the name of each result.
+func queryAll(data any, path string, opts []QueryOption) ([]any, []string, error) {
+	cfg := newQueryConfig(opts)
+
 	if path == "" {
-		return []any{data}, nil
+		return []any{data}, []string{""}, nil
+	}
+	if parts := splitPredicate(path, "|"); len(parts) > 1 {
+		return queryUnion(data, parts, opts)
 	}
 
+	fullPath := path
+	currentCandidates := []any{data}
+	var names []string
 	if strings.HasPrefix(path, "//") {
-		targetKey := strings.TrimPrefix(path, "//")
-		return findAllRecursively(data, targetKey), nil
+		head, tail, _ := strings.Cut(strings.TrimPrefix(path, "//"), "/")
+		currentCandidates, names = deepSegment(data, head, cfg)
+		if len(currentCandidates) == 0 && cfg.strict {
+			return nil, nil, fmt.Errorf("query %q: no match for deep search '%s'", fullPath, head)
+		}
+		if len(currentCandidates) == 0 || tail == "" {
+			return currentCandidates, names, nil
+		}
+		path = tail
 	}
 
 	segments := strings.Split(path, "/")
-	currentCandidates := []any{data}
-
-	for _, segment := range segments {
+	deep := false
+	for i, segment := range segments {
 		if segment == "" {
+			// "a//b": the next segment searches below the current nodes
+			deep = i > 0
 			continue
 		}
 		var nextCandidates []any
-		for _, candidate := range currentCandidates {
-			nodesToSearch := []any{candidate}
-			if list, ok := candidate.([]any); ok {
-				nodesToSearch = list
+		var nextNames []string
+		if deep {
+			for _, c := range currentCandidates {
+				found, foundNames := deepSegment(c, segment, cfg)
+				nextCandidates = append(nextCandidates, found...)
+				nextNames = append(nextNames, foundNames...)
 			}
+			deep = false
+		} else {
+			nextCandidates, nextNames, _ = querySegment(currentCandidates, names, segment, cfg)
+		}
+		if len(nextCandidates) == 0 {
+			if cfg.strict {
+				return nil, nil, fmt.Errorf("query %q: no match at segment '%s' (%d of %d)", fullPath, segment, i+1, len(segments))
+			}
+			return nil, nil, nil // Not found
+		}
+		currentCandidates, names = nextCandidates, nextNames
+	}
+	return currentCandidates, names, nil
+}
 
-			// #count logic
-			if segment == "#count" {
-				val := 0
-				if list, ok := candidate.([]any); ok {
-					val = len(list)
-				} else if m, ok := candidate.(*OrderedMap); ok {
-					val = m.Len()
-				} else if m, ok := candidate.(map[string]any); ok {
-					val = len(m)
+// queryUnion evaluates the alternatives of a "|" path. In strict mode it only
+// fails when no alternative matches anything.
+func queryUnion(data any, parts []string, opts []QueryOption) ([]any, []string, error) {
+	var results []any
+	var names []string
+	var firstErr error
+	seen := make(map[*OrderedMap]bool)
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		found, foundNames, err := queryAll(data, part, opts)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for i, v := range found {
+			if m, ok := v.(*OrderedMap); ok {
+				if seen[m] {
+					continue
 				}
-				nextCandidates = append(nextCandidates, val)
-				continue
+				seen[m] = true
 			}
+			results = append(results, v)
+			names = append(names, foundNames[i])
+		}
+	}
+	if len(results) == 0 {
+		return nil, nil, firstErr
+	}
+	return results, names, nil
+}
 
-			for _, node := range nodesToSearch {
-				key, fParams, idx := parseSegment(segment)
+// querySegment applies a single path segment to the current candidates.
+// names holds, for each candidate, the key it was stored under (used by the
+// "#name" segment); the names of the surviving candidates are returned
+// alongside them. It also returns how many values matched the segment key
+// before any filter or index was applied (used by ExplainPath).
+func querySegment(currentCandidates []any, names []string, segment string, cfg *queryConfig) ([]any, []string, int) {
+	var nextCandidates []any
+	var nextNames []string
+	matched := 0
+	key, fParams, idx := parseSegment(segment)
+	keyMatch := keyMatcher(key)
 
-				if key == "#text" {
-					switch node.(type) {
-					case string, int, float64, bool:
-						nextCandidates = append(nextCandidates, node)
-						continue
-					}
+	emit := func(name string, v any) {
+		nextCandidates = append(nextCandidates, v)
+		nextNames = append(nextNames, name)
+	}
+
+	for ci, candidate := range currentCandidates {
+		nodesToSearch := []any{candidate}
+		if list, ok := candidate.([]any); ok {
+			nodesToSearch = list
+		}
+
+		// #name logic: the key the node was found under
+		if segment == "#name" {
+			if ci < len(names) && names[ci] != "" {
+				for range nodesToSearch {
+					emit(names[ci], names[ci])
+					matched++
 				}
+			}
+			continue
+		}
 
-				var valuesToProcess []any
+		// #count logic
+		if segment == "#count" {
+			val := 0
+			if list, ok := candidate.([]any); ok {
+				val = len(list)
+			} else if m, ok := candidate.(*OrderedMap); ok {
+				val = m.Len()
+			} else if m, ok := candidate.(map[string]any); ok {
+				val = len(m)
+			}
+			emit("#count", val)
+			matched++
+			continue
+		}
 
-				if m, ok := node.(*OrderedMap); ok {
-					if key == "*" {
-						m.ForEach(func(k string, v any) bool {
-							if !strings.HasPrefix(k, "@") && !strings.HasPrefix(k, "#") {
-								valuesToProcess = append(valuesToProcess, v)
-							}
-							return true
-						})
-					} else if strings.HasPrefix(key, "func:") {
-						funcName := strings.TrimPrefix(key, "func:")
-						if fn, ok := getQueryFunction(funcName); ok {
-							m.ForEach(func(k string, v any) bool {
-								if !strings.HasPrefix(k, "@") && !strings.HasPrefix(k, "#") {
-									if fn(k) {
-										valuesToProcess = append(valuesToProcess, v)
-									}
-								}
-								return true
-							})
+		for _, node := range nodesToSearch {
+			if key == "#text" {
+				switch node.(type) {
+				case string, int, float64, bool:
+					emit("#text", node)
+					matched++
+					continue
+				}
+			}
+
+			var keysToProcess []string
+			var valuesToProcess []any
+			add := func(k string, v any) {
+				keysToProcess = append(keysToProcess, k)
+				valuesToProcess = append(valuesToProcess, v)
+			}
+
+			if m, ok := node.(*OrderedMap); ok {
+				if keyMatch != nil {
+					m.ForEach(func(k string, v any) bool {
+						if isPlainKey(k) && keyMatch(k) {
+							add(k, v)
 						}
-					} else {
-						if val := m.Get(key); val != nil {
-							valuesToProcess = append(valuesToProcess, val)
+						return true
+					})
+				} else {
+					if val := m.Get(key); val != nil {
+						add(key, val)
+					} else if cfg.attrFallback && isPlainKey(key) {
+						if val := m.Get("@" + key); val != nil {
+							add("@"+key, val)
 						}
 					}
-				} else if m, ok := node.(map[string]any); ok {
-					if key == "*" {
-						var keys []string
-						for k := range m {
-							if !strings.HasPrefix(k, "@") && !strings.HasPrefix(k, "#") {
-								keys = append(keys, k)
-							}
-						}
-						sort.Strings(keys)
-						for _, k := range keys {
-							valuesToProcess = append(valuesToProcess, m[k])
-						}
-					} else if strings.HasPrefix(key, "func:") {
-						funcName := strings.TrimPrefix(key, "func:")
-						if fn, ok := getQueryFunction(funcName); ok {
-							var keys []string
-							for k := range m {
-								if !strings.HasPrefix(k, "@") && !strings.HasPrefix(k, "#") {
-									if fn(k) {
-										keys = append(keys, k)
-									}
-								}
-							}
-							sort.Strings(keys)
-							for _, k := range keys {
-								valuesToProcess = append(valuesToProcess, m[k])
-							}
+				}
+			} else if m, ok := node.(map[string]any); ok {
+				if keyMatch != nil {
+					var keys []string
+					for k := range m {
+						if isPlainKey(k) && keyMatch(k) {
+							keys = append(keys, k)
 						}
-					} else {
-						if val, exists := m[key]; exists {
-							valuesToProcess = append(valuesToProcess, val)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						add(k, m[k])
+					}
+				} else {
+					if val, exists := m[key]; exists {
+						add(key, val)
+					} else if cfg.attrFallback && isPlainKey(key) {
+						if val, exists := m["@"+key]; exists {
+							add("@"+key, val)
 						}
 					}
 				}
+			}
 
-				for _, val := range valuesToProcess {
-					if fParams != nil {
-						if list, ok := val.([]any); ok {
-							for _, item := range list {
-								if matchFilter(item, fParams) {
-									nextCandidates = append(nextCandidates, item)
-								}
-							}
-						} else {
-							if matchFilter(val, fParams) {
-								nextCandidates = append(nextCandidates, val)
+			for vi, val := range valuesToProcess {
+				name := keysToProcess[vi]
+				if fParams != nil {
+					if list, ok := val.([]any); ok {
+						matched += len(list)
+						for i, item := range list {
+							if matchPredicate(item, i+1, len(list), fParams, cfg) {
+								emit(name, item)
 							}
 						}
-					} else if idx >= 0 {
-						if list, ok := val.([]any); ok {
-							if idx < len(list) {
-								nextCandidates = append(nextCandidates, list[idx])
-							}
+					} else {
+						matched++
+						if matchPredicate(val, 1, 1, fParams, cfg) {
+							emit(name, val)
+						}
+					}
+				} else if idx >= 0 {
+					if list, ok := val.([]any); ok {
+						matched += len(list)
+						if idx < len(list) {
+							emit(name, list[idx])
 						}
 					} else {
-						nextCandidates = append(nextCandidates, val)
+						matched++
 					}
+				} else {
+					matched++
+					emit(name, val)
 				}
 			}
 		}
+	}
+	return nextCandidates, nextNames, matched
+}
+
+// keyMatcher returns the key predicate for segments that select several
+// children: "*" (any child), "func:name" (registered QueryFunction) and glob
+// patterns such as "box_*" or "*_id". It returns nil for a plain key, which
+// is looked up directly.
+func keyMatcher(key string) func(string) bool {
+	switch {
+	case key == "*":
+		return func(string) bool { return true }
+	case strings.HasPrefix(key, "func:"):
+		if fn, ok := getQueryFunction(strings.TrimPrefix(key, "func:")); ok {
+			return fn
+		}
+		return func(string) bool { return false }
+	case strings.Contains(key, "*"):
+		return func(k string) bool {
+			ok, _ := path.Match(key, k)
+			return ok
+		}
+	}
+	return nil
+}
+
+// isPlainKey reports whether key names a child element (not an attribute or
+// a "#" meta key).
+func isPlainKey(key string) bool {
+	return !strings.HasPrefix(key, "@") && !strings.HasPrefix(key, "#")
+}
+
+// ExplainPath runs path against data segment by segment and reports how
+// many candidates matched each segment key ("in") and how many survived its
+// filter or index ("out"). Useful to find out why a query returns nothing:
+//
+//	segment 'store': 1 in → 1 out
+//	segment 'book[price<10]': 4 in → 2 out
+//	segment 'title': 2 in → 2 out
+func ExplainPath(data any, path string) string {
+	if path == "" {
+		return "empty path: returns the input itself\n"
+	}
+
+	var sb strings.Builder
+
+	currentCandidates := []any{data}
+	var names []string
+	if strings.HasPrefix(path, "//") {
+		head, tail, _ := strings.Cut(strings.TrimPrefix(path, "//"), "/")
+		currentCandidates, names = deepSegment(data, head, &queryConfig{})
+		fmt.Fprintf(&sb, "deep search '%s': %d out\n", head, len(currentCandidates))
+		if len(currentCandidates) == 0 || tail == "" {
+			return sb.String()
+		}
+		path = tail
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		nextCandidates, nextNames, matched := querySegment(currentCandidates, names, segment, &queryConfig{})
+		fmt.Fprintf(&sb, "segment '%s': %d in → %d out\n", segment, matched, len(nextCandidates))
 		if len(nextCandidates) == 0 {
-			return nil, nil // Not found
+			fmt.Fprintf(&sb, "stopped at segment '%s': no candidates left\n", segment)
+			break
 		}
-		currentCandidates = nextCandidates
+		currentCandidates, names = nextCandidates, nextNames
 	}
-	return currentCandidates, nil
+	return sb.String()
 }
 
 type filterParams struct {
@@ -161,6 +398,13 @@ type filterParams struct {
 	Op     string
 	Val    string
 	IsFunc bool
+	Mod    int // position() mod N (0 = plain position())
+
+	// Compound filters ("a and b", "a or b"): when Sub is set the fields
+	// above are unused and the item must match all (or, with Any, one) of
+	// the sub-filters.
+	Sub []*filterParams
+	Any bool
 }
 
 func parseSegment(seg string) (key string, fp *filterParams, idx int) {
@@ -170,6 +414,10 @@ func parseSegment(seg string) (key string, fp *filterParams, idx int) {
 		key = seg[:i]
 		inside := seg[i+1 : len(seg)-1]
 
+		if fp := parseCompound(inside); fp != nil {
+			return key, fp, -1
+		}
+
 		if strings.Contains(inside, "(") && strings.Contains(inside, ")") {
 			pIndex := strings.Index(inside, "(")
 			funcName := strings.TrimSpace(inside[:pIndex])
@@ -183,6 +431,11 @@ func parseSegment(seg string) (key string, fp *filterParams, idx int) {
 			}
 		}
 
+		// [last()] and [last()-N] select by position from the end
+		if strings.HasPrefix(strings.TrimSpace(inside), "last()") {
+			return key, &filterParams{Key: "position()", Op: "=", Val: strings.TrimSpace(inside)}, -1
+		}
+
 		ops := []string{"!=", ">=", "<=", "=", ">", "<"}
 		for _, op := range ops {
 			if strings.Contains(inside, op) {
@@ -190,7 +443,14 @@ func parseSegment(seg string) (key string, fp *filterParams, idx int) {
 				fKey := strings.TrimSpace(parts[0])
 				fVal := strings.TrimSpace(parts[1])
 				fVal = strings.Trim(fVal, "'\"")
-				return key, &filterParams{Key: fKey, Op: op, Val: fVal, IsFunc: false}, -1
+				fp = &filterParams{Key: fKey, Op: op, Val: fVal, IsFunc: false}
+				if rest, ok := strings.CutPrefix(fKey, "position()"); ok {
+					fp.Key = "position()"
+					if n, ok := strings.CutPrefix(strings.TrimSpace(rest), "mod "); ok {
+						fp.Mod, _ = strconv.Atoi(strings.TrimSpace(n))
+					}
+				}
+				return key, fp, -1
 			}
 		}
 
@@ -201,33 +461,119 @@ func parseSegment(seg string) (key string, fp *filterParams, idx int) {
 	return
 }
 
-func matchFilter(item any, fp *filterParams) bool {
-	var actual any
-	found := false
+// parseCompound parses "cond and cond" / "cond or cond" filters, where each
+// cond is any single filter (comparison, function or position()). "and"
+// binds tighter than "or". It returns nil if inside has no top-level
+// and/or.
+func parseCompound(inside string) *filterParams {
+	for _, op := range []string{" or ", " and "} {
+		parts := splitPredicate(inside, op)
+		if len(parts) < 2 {
+			continue
+		}
+		fp := &filterParams{Any: op == " or "}
+		for _, part := range parts {
+			_, sub, _ := parseSegment("_[" + strings.TrimSpace(part) + "]")
+			if sub == nil {
+				return nil
+			}
+			fp.Sub = append(fp.Sub, sub)
+		}
+		return fp
+	}
+	return nil
+}
+
+// splitPredicate splits s on sep, ignoring occurrences inside quotes,
+// parentheses or brackets (so "contains(name,' and ')" stays whole).
+func splitPredicate(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i = start - 1
+		}
+	}
+	return append(parts, s[start:])
+}
 
-	if m, ok := item.(*OrderedMap); ok {
-		if v := m.Get(fp.Key); v != nil {
-			actual = v
-			found = true
-		} else if v := m.Get("@" + fp.Key); v != nil {
-			actual = v
-			found = true
+// matchPredicate evaluates a filter against the item at 1-based position
+// pos among its size same-named siblings. position() predicates
+// ("position() <= 2", "position() mod 2 = 0", "position() = last()") compare
+// the position; anything else is matchFilter.
+func matchPredicate(item any, pos, size int, fp *filterParams, cfg *queryConfig) bool {
+	if fp.Sub != nil {
+		for _, sub := range fp.Sub {
+			if matchPredicate(item, pos, size, sub, cfg) == fp.Any {
+				return fp.Any
+			}
 		}
-	} else if m, ok := item.(map[string]any); ok {
-		if v, exists := m[fp.Key]; exists {
-			actual = v
-			found = true
-		} else if v, exists := m["@"+fp.Key]; exists {
-			actual = v
-			found = true
+		return !fp.Any
+	}
+	if fp.IsFunc || fp.Key != "position()" {
+		return matchFilter(item, fp, cfg)
+	}
+	lhs := pos
+	if fp.Mod > 0 {
+		lhs = pos % fp.Mod
+	}
+	target, ok := positionTarget(fp.Val, size)
+	if !ok {
+		return false
+	}
+	return compareNumbers(float64(lhs), target, fp.Op)
+}
+
+// positionTarget resolves the right-hand side of a position() comparison:
+// a number, "last()" (size) or "last()-N".
+func positionTarget(val string, size int) (float64, bool) {
+	if rest, ok := strings.CutPrefix(val, "last()"); ok {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return float64(size), true
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(rest, "-")))
+		if err != nil || !strings.HasPrefix(rest, "-") {
+			return 0, false
 		}
+		return float64(size - n), true
+	}
+	target, err := strconv.ParseFloat(val, 64)
+	return target, err == nil
+}
+
+// matchFilter evaluates a field filter ("key op value" or a function such
+// as contains) against item. Unless cfg.exactMatch is set, comparisons trim
+// both sides; function arguments are always used as written.
+func matchFilter(item any, fp *filterParams, cfg *queryConfig) bool {
+	actual, found := lookupField(item, fp.Key)
+
+	// An empty right-hand side on != means "absent or empty": the filter
+	// keeps only nodes where the field exists with a non-empty value.
+	if fp.Op == "!=" && fp.Val == "" && !fp.IsFunc {
+		return found && filterText(actual) != ""
 	}
 
 	if !found {
 		return false
 	}
 
-	actualStr := fmt.Sprintf("%v", actual)
+	actualStr := filterText(actual)
+	val := fp.Val
 
 	if fp.IsFunc {
 		switch fp.Op {
@@ -239,31 +585,125 @@ func matchFilter(item any, fp *filterParams) bool {
 		return false
 	}
 
+	if !cfg.exactMatch {
+		actualStr = strings.TrimSpace(actualStr)
+		val = strings.TrimSpace(val)
+	}
+
 	switch fp.Op {
-	case "=":
-		return actualStr == fp.Val
-	case "!=":
-		return actualStr != fp.Val
+	case "=", "!=":
+		// Numeric when both sides parse as numbers (8.95 = 8.950), so that
+		// = and != always select complementary nodes.
+		numV, errV := strconv.ParseFloat(actualStr, 64)
+		targetV, errT := strconv.ParseFloat(val, 64)
+		if errV == nil && errT == nil {
+			return compareNumbers(numV, targetV, fp.Op)
+		}
+		return (actualStr == val) == (fp.Op == "=")
 	case ">", "<", ">=", "<=":
 		numV, errV := strconv.ParseFloat(actualStr, 64)
-		targetV, errT := strconv.ParseFloat(fp.Val, 64)
-		if errV != nil || errT != nil {
-			return false
+		targetV, errT := strconv.ParseFloat(val, 64)
+		if errV == nil && errT == nil {
+			return compareNumbers(numV, targetV, fp.Op)
 		}
-		switch fp.Op {
-		case ">":
-			return numV > targetV
-		case "<":
-			return numV < targetV
-		case ">=":
-			return numV >= targetV
-		case "<=":
-			return numV <= targetV
+		// Chronological when both sides are dates ("date > '2025-01-01'")
+		dateV, okV := filterTime(actual)
+		dateT, errT := AsTime(val)
+		if okV && errT == nil {
+			return compareNumbers(float64(dateV.Compare(dateT)), 0, fp.Op)
 		}
+		return false
+	}
+	return false
+}
+
+// filterTime reads a filter operand as a date/time (see AsTime).
+func filterTime(v any) (time.Time, bool) {
+	if t, ok := v.(time.Time); ok {
+		return t, true
+	}
+	t, err := AsTime(filterText(v))
+	return t, err == nil
+}
+
+func compareNumbers(a, b float64, op string) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
 	}
 	return false
 }
 
+// lookupField returns the child (or, failing that, the attribute) key of a
+// node, as filters and QueryGroupBy see it.
+func lookupField(item any, key string) (any, bool) {
+	if m, ok := item.(*OrderedMap); ok {
+		if v := m.Get(key); v != nil {
+			return v, true
+		} else if v := m.Get("@" + key); v != nil {
+			return v, true
+		}
+	} else if m, ok := item.(map[string]any); ok {
+		if v, exists := m[key]; exists {
+			return v, true
+		} else if v, exists := m["@"+key]; exists {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// filterText returns the comparable text of a filter field: scalars as-is,
+// complex nodes (e.g. an element with attributes) by their #text, or by
+// their full text content when they have no direct text.
+func filterText(v any) string {
+	switch n := v.(type) {
+	case *OrderedMap:
+		if t := n.Get("#text"); t != nil {
+			return fmt.Sprintf("%v", t)
+		}
+		return Text(n)
+	case map[string]any:
+		if t, ok := n["#text"]; ok {
+			return fmt.Sprintf("%v", t)
+		}
+		return Text(n)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// deepSegment resolves the first segment of a "//" path. A plain key returns
+// every value stored under it anywhere in data; a segment carrying a filter
+// or index ("book[price>20]", "book[0]") applies it to each occurrence, as
+// if the segment had been reached by a regular path.
+func deepSegment(data any, segment string, cfg *queryConfig) ([]any, []string) {
+	key, fParams, idx := parseSegment(segment)
+	if fParams == nil && idx < 0 {
+		found := findAllRecursively(data, segment)
+		names := make([]string, len(found))
+		for i := range names {
+			names[i] = segment
+		}
+		return found, names
+	}
+	var parents []any
+	for _, val := range findAllRecursively(data, key) {
+		parents = append(parents, map[string]any{key: val})
+	}
+	res, names, _ := querySegment(parents, nil, segment, cfg)
+	return res, names
+}
+
 func findAllRecursively(data any, targetKey string) []any {
 	var results []any
 	var traverse func(node any)
@@ -299,8 +739,8 @@ func findAllRecursively(data any, targetKey string) []any {
 }
 
 // Query is a convenience wrapper around QueryAll that returns the first matching result.
-func Query(data any, path string) (any, error) {
-	res, err := QueryAll(data, path)
+func Query(data any, path string, opts ...QueryOption) (any, error) {
+	res, err := QueryAll(data, path, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -311,9 +751,9 @@ func Query(data any, path string) (any, error) {
 }
 
 // Get performs a Query and returns the typed value T.
-func Get[T any](data any, path string) (T, error) {
+func Get[T any](data any, path string, opts ...QueryOption) (T, error) {
 	var zero T
-	val, err := Query(data, path)
+	val, err := Query(data, path, opts...)
 	if err != nil {
 		return zero, err
 	}
@@ -335,13 +775,182 @@ func Get[T any](data any, path string) (T, error) {
 	return zero, fmt.Errorf("value at %s is %T, expected %T", path, val, zero)
 }
 
+// QueryNode is Query with a stable return type: element nodes are returned
+// as-is, and simplified scalars (elements without attributes or children)
+// are wrapped into a map with a single "#text" key. This makes
+// <name>Ana</name> and <name lang="es">Ana</name> read the same way.
+func QueryNode(data any, path string, opts ...QueryOption) (*OrderedMap, error) {
+	val, err := Query(data, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case *OrderedMap:
+		return v, nil
+	case map[string]any:
+		node := NewMap()
+		for _, k := range sortedKeys(v) {
+			node.Put(k, v[k])
+		}
+		return node, nil
+	case []any:
+		return nil, fmt.Errorf("value at %s is a list of %d items, not a single node", path, len(v))
+	}
+	node := NewMap()
+	node.Put("#text", val)
+	return node, nil
+}
+
+// QueryString is Query with a stable return type: scalars are formatted
+// with %v and element nodes yield their "#text" (or, without direct text,
+// their full text content), whether or not they carry attributes.
+func QueryString(data any, path string, opts ...QueryOption) (string, error) {
+	val, err := Query(data, path, opts...)
+	if err != nil {
+		return "", err
+	}
+	return filterText(val), nil
+}
+
+// QueryMap runs QueryAll and passes every result through fn, returning the
+// transformed values as a typed slice. An empty result is not an error.
+func QueryMap[T any](data any, path string, fn func(any) T, opts ...QueryOption) ([]T, error) {
+	results, err := QueryAll(data, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, 0, len(results))
+	for _, r := range results {
+		out = append(out, fn(r))
+	}
+	return out, nil
+}
+
+// QueryGroupBy runs QueryAll and buckets the matched nodes by the text of
+// their groupField child or attribute ("" for nodes without it), keeping
+// document order inside each bucket.
+//
+//	byCategory, _ := xml.QueryGroupBy(doc, "store/book", "category")
+//	fiction := byCategory["fiction"]
+func QueryGroupBy(data any, path, groupField string, opts ...QueryOption) (map[string][]any, error) {
+	results, err := QueryAll(data, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string][]any)
+	for _, r := range results {
+		for _, item := range AsSlice(r) {
+			key := ""
+			if v, ok := lookupField(item, groupField); ok {
+				key = filterText(v)
+			}
+			groups[key] = append(groups[key], item)
+		}
+	}
+	return groups, nil
+}
+
+// Transform builds a new document from template, resolving "{{path}}"
+// placeholders against source with Query. A string that is exactly one
+// placeholder takes the resolved value as-is (a scalar, or a whole node);
+// placeholders embedded in a longer string are replaced by their text
+// ("Total: {{Body/Total}} COP"). Keys, attributes and nested maps/lists of
+// the template are kept in order. A placeholder whose path resolves to
+// nothing is an error.
+func Transform(source any, template *OrderedMap) (*OrderedMap, error) {
+	out, err := transformValue(source, template)
+	if err != nil {
+		return nil, err
+	}
+	return out.(*OrderedMap), nil
+}
+
+func transformValue(source any, tmpl any) (any, error) {
+	switch t := tmpl.(type) {
+	case *OrderedMap:
+		out := NewMap()
+		for _, k := range t.Keys() {
+			v, err := transformValue(source, t.Get(k))
+			if err != nil {
+				return nil, err
+			}
+			out.Put(k, v)
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(t))
+		for i, item := range t {
+			v, err := transformValue(source, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case string:
+		return transformString(source, t)
+	}
+	return tmpl, nil
+}
+
+func transformString(source any, s string) (any, error) {
+	if strings.HasPrefix(s, "{{") && strings.HasSuffix(s, "}}") && strings.Count(s, "{{") == 1 {
+		path := strings.TrimSpace(s[2 : len(s)-2])
+		val, err := Query(source, path)
+		if err != nil {
+			return nil, fmt.Errorf("transform: %s: %w", path, err)
+		}
+		return val, nil
+	}
+
+	var sb strings.Builder
+	rest := s
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			break
+		}
+		path := strings.TrimSpace(rest[start+2 : start+end])
+		text, err := QueryString(source, path)
+		if err != nil {
+			return nil, fmt.Errorf("transform: %s: %w", path, err)
+		}
+		sb.WriteString(rest[:start])
+		sb.WriteString(text)
+		rest = rest[start+end+2:]
+	}
+	sb.WriteString(rest)
+	return sb.String(), nil
+}
+
 // Rule defines a validation constraint for the Validate engine.
 type Rule struct {
 	Path     string
 	Required bool
 	Type     string
-	Min      float64
-	Max      float64
-	Regex    string
-	Enum     []string
+	Min      float64  // Applies when non-zero, or when HasMin is set
+	Max      float64  // Applies when non-zero, or when HasMax is set
+	HasMin   bool     // Apply Min even when it is 0
+	HasMax   bool     // Apply Max even when it is 0
+	Regex    string   // Matched against the text, with Type "string" or no Type
+	Enum     []string // Allowed values, for any Type but "array"
+	MinLen   int      // Minimum length: characters for "string", items for "array"
+	MaxLen   int      // Maximum length (0 = no limit)
+	Format   string   // Named string format: "email", "uuid", "url" or "date"
+
+	// When makes the whole rule conditional: it is only checked when the
+	// condition holds. When comes first, so a Required rule whose
+	// condition does not hold never reports the path as missing.
+	When *Condition
+}
+
+// Condition is the When clause of a Rule: it holds when the value at Path
+// (an element's text or an attribute) equals Equals.
+type Condition struct {
+	Path   string
+	Equals string
 }
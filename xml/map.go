@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,6 +27,22 @@ func NewMap() *OrderedMap {
 	}
 }
 
+// Pair is a single key/value entry of an OrderedMap (see Pairs/FromPairs).
+type Pair struct {
+	Key   string
+	Value any
+}
+
+// FromPairs builds an OrderedMap from pairs, in order. A repeated key keeps
+// its first position and takes the last value, as with Put.
+func FromPairs(pairs []Pair) *OrderedMap {
+	om := NewMap()
+	for _, p := range pairs {
+		om.Put(p.Key, p.Value)
+	}
+	return om
+}
+
 // ---------------------------------------------------------
 // 1. Fluent Setters & Core CRUD
 // ---------------------------------------------------------
@@ -70,6 +88,17 @@ func (om *OrderedMap) Put(key string, value any) {
 	om.values[key] = value
 }
 
+// PutFront inserts a key-value pair as the first key at this level. An
+// existing key is moved to the front. Useful to keep namespace declarations
+// first when rebuilding a node.
+func (om *OrderedMap) PutFront(key string, value any) {
+	if _, exists := om.values[key]; exists {
+		om.Remove(key)
+	}
+	om.keys = append([]string{key}, om.keys...)
+	om.values[key] = value
+}
+
 // Get retrieves a direct value from this level.
 func (om *OrderedMap) Get(key string) any {
 	return om.values[key]
@@ -248,6 +277,40 @@ func (om *OrderedMap) Keys() []string {
 	return result
 }
 
+// Pairs returns the direct entries of this level as key/value pairs, in
+// order. Values are not copied.
+func (om *OrderedMap) Pairs() []Pair {
+	pairs := make([]Pair, len(om.keys))
+	for i, k := range om.keys {
+		pairs[i] = Pair{Key: k, Value: om.values[k]}
+	}
+	return pairs
+}
+
+// Attributes returns the attributes of this level ("@" keys) with the "@"
+// stripped: {"id": "7", "lang": "en"}. Values are not copied.
+func (om *OrderedMap) Attributes() map[string]any {
+	attrs := make(map[string]any)
+	for _, k := range om.keys {
+		if name, ok := strings.CutPrefix(k, "@"); ok {
+			attrs[name] = om.values[k]
+		}
+	}
+	return attrs
+}
+
+// AttributeKeys returns the attribute names of this level in order, without
+// the "@" prefix.
+func (om *OrderedMap) AttributeKeys() []string {
+	var names []string
+	for _, k := range om.keys {
+		if name, ok := strings.CutPrefix(k, "@"); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // Sort sorts the keys alphabetically.
 func (om *OrderedMap) Sort() {
 	sort.Strings(om.keys)
@@ -262,6 +325,59 @@ func (om *OrderedMap) ForEach(fn func(key string, value any) bool) {
 	}
 }
 
+// Leaves walks the map depth-first, in order, and calls fn for every scalar
+// value (string, number, bool...) with its full path, e.g. "order/@id" or
+// "order/item[1]/sku" for list elements. Returning false stops the walk.
+func (om *OrderedMap) Leaves(fn func(path string, value any) bool) {
+	walkLeaves("", om, fn)
+}
+
+// walkLeaves visits the leaves under val, reporting false once fn stops.
+func walkLeaves(path string, val any, fn func(path string, value any) bool) bool {
+	child := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "/" + key
+	}
+	switch v := val.(type) {
+	case nil:
+		return true
+	case *OrderedMap:
+		for _, k := range v.keys {
+			if !walkLeaves(child(k), v.values[k], fn) {
+				return false
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !walkLeaves(child(k), v[k], fn) {
+				return false
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if !walkLeaves(fmt.Sprintf("%s[%d]", path, i), item, fn) {
+				return false
+			}
+		}
+	case []*OrderedMap:
+		for i, item := range v {
+			if !walkLeaves(fmt.Sprintf("%s[%d]", path, i), item, fn) {
+				return false
+			}
+		}
+	default:
+		return fn(path, v)
+	}
+	return true
+}
+
 // ToMap converts recursively to map[string]any (loses order).
 func (om *OrderedMap) ToMap() map[string]any {
 	result := make(map[string]any, len(om.keys))
@@ -379,6 +495,51 @@ func (om *OrderedMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	return e.EncodeToken(finalStart.End())
 }
 
+// UnmarshalXML makes *OrderedMap a target for encoding/xml (xml.Unmarshal,
+// Decoder.DecodeElement and so NewStream): the element is parsed as by
+// MapXMLRoot, so om receives its attributes, text and children.
+func (om *OrderedMap) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// The element's tokens go through the regular parser, which owns the
+	// simplification rules.
+	depth := 0
+	var tok xml.Token = start
+	m, err := buildMap(func() (xml.Token, bool, error) {
+		if depth < 0 {
+			return nil, false, io.EOF
+		}
+		if tok == nil {
+			var err error
+			if tok, err = d.Token(); err != nil {
+				return nil, false, err
+			}
+		}
+		t := tok
+		tok = nil
+		switch t.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth--; depth == 0 {
+				depth = -1 // The element is complete
+			}
+		}
+		return t, false, nil
+	}, newConfig(nil))
+	if err != nil {
+		return err
+	}
+
+	if om.values == nil {
+		om.values = make(map[string]any)
+	}
+	content := rootContent(m)
+	content.ForEach(func(k string, v any) bool {
+		om.Put(k, v)
+		return true
+	})
+	return nil
+}
+
 // ---------------------------------------------------------
 // 5. Debug Helper
 // ---------------------------------------------------------
@@ -450,3 +611,437 @@ func (om *OrderedMap) Move(srcPath, dstPath string) error {
 	}
 	return nil
 }
+
+// PruneOption configures Prune.
+type PruneOption func(*pruneConfig)
+
+type pruneConfig struct {
+	emptyStrings bool
+}
+
+// PruneEmptyStrings makes Prune also remove keys (elements and attributes)
+// whose value is "".
+func PruneEmptyStrings() PruneOption {
+	return func(c *pruneConfig) { c.emptyStrings = true }
+}
+
+// Prune recursively removes keys whose value is an empty map or an empty
+// list, so they are not encoded as empty elements. Branches that become
+// empty once pruned are removed too, as are empty maps inside lists.
+func (om *OrderedMap) Prune(opts ...PruneOption) {
+	cfg := &pruneConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	pruneMap(om, cfg)
+}
+
+func pruneMap(om *OrderedMap, cfg *pruneConfig) {
+	for _, k := range om.Keys() {
+		if v, keep := pruneValue(om.Get(k), cfg); keep {
+			om.Put(k, v)
+		} else {
+			om.Remove(k)
+		}
+	}
+}
+
+// pruneValue prunes v and reports whether anything is left of it.
+func pruneValue(v any, cfg *pruneConfig) (any, bool) {
+	switch t := v.(type) {
+	case *OrderedMap:
+		pruneMap(t, cfg)
+		return t, t.Len() > 0
+	case map[string]any:
+		for k, val := range t {
+			if pruned, keep := pruneValue(val, cfg); keep {
+				t[k] = pruned
+			} else {
+				delete(t, k)
+			}
+		}
+		return t, len(t) > 0
+	case []any:
+		kept := t[:0]
+		for _, item := range t {
+			if pruned, keep := pruneValue(item, cfg); keep {
+				kept = append(kept, pruned)
+			}
+		}
+		return kept, len(kept) > 0
+	case []*OrderedMap:
+		kept := t[:0]
+		for _, item := range t {
+			if pruneMap(item, cfg); item.Len() > 0 {
+				kept = append(kept, item)
+			}
+		}
+		return kept, len(kept) > 0
+	case string:
+		return t, t != "" || !cfg.emptyStrings
+	}
+	return v, true
+}
+
+// MergeAt merges fragment into base at path, creating intermediate nodes
+// as needed. Unlike Set, existing content is kept: a map fragment is merged
+// key by key (recursively) into the node at path, attributes and "#" keys
+// are overwritten, and any other element already present becomes a
+// repeated element with the new value appended. A list fragment is always
+// appended, which is how a repeated record is added next to an existing
+// one. An empty path merges into base itself.
+//
+//	xml.MergeAt(doc, "Invoice/ext:UBLExtensions/ext:UBLExtension/ext:ExtensionContent",
+//		xml.NewMap().Set("ds:Signature", sig))
+func MergeAt(base *OrderedMap, path string, fragment any) error {
+	if base == nil {
+		return fmt.Errorf("merge: base map is nil")
+	}
+	if path == "" {
+		src, ok := fragment.(*OrderedMap)
+		if !ok {
+			return fmt.Errorf("merge: fragment at the root must be an *OrderedMap, got %T", fragment)
+		}
+		mergeMaps(base, src)
+		return nil
+	}
+
+	parts := strings.Split(path, "/")
+	current := base
+	for i, key := range parts[:len(parts)-1] {
+		if !current.Has(key) {
+			next := NewMap()
+			current.Put(key, next)
+			current = next
+			continue
+		}
+		next, ok := current.Get(key).(*OrderedMap)
+		if !ok {
+			return fmt.Errorf("merge: '%s' is a %T, not a node", strings.Join(parts[:i+1], "/"), current.Get(key))
+		}
+		current = next
+	}
+
+	last := parts[len(parts)-1]
+	if !current.Has(last) {
+		current.Put(last, fragment)
+		return nil
+	}
+	current.Put(last, mergeValues(last, current.Get(last), fragment))
+	return nil
+}
+
+// mergeMaps merges the keys of src into dst (see MergeAt).
+func mergeMaps(dst, src *OrderedMap) {
+	src.ForEach(func(k string, v any) bool {
+		if !dst.Has(k) {
+			dst.Put(k, v)
+		} else {
+			dst.Put(k, mergeValues(k, dst.Get(k), v))
+		}
+		return true
+	})
+}
+
+// mergeValues combines an existing value with an incoming one under key:
+// maps are merged, attributes and "#" keys replaced, and other elements
+// (or a list) collected into a list of repeated elements.
+func mergeValues(key string, existing, incoming any) any {
+	if !isPlainKey(key) {
+		return incoming
+	}
+	if dst, ok := existing.(*OrderedMap); ok {
+		if src, ok := incoming.(*OrderedMap); ok {
+			mergeMaps(dst, src)
+			return dst
+		}
+	}
+	return append(asList(existing), asList(incoming)...)
+}
+
+// asList returns v as the items of a repeated element.
+func asList(v any) []any {
+	switch t := v.(type) {
+	case []any:
+		return append([]any(nil), t...)
+	case []*OrderedMap:
+		out := make([]any, len(t))
+		for i, item := range t {
+			out[i] = item
+		}
+		return out
+	}
+	return []any{v}
+}
+
+// ---------------------------------------------------------
+// 7. Comparison (Diff)
+// ---------------------------------------------------------
+
+// Change is a single difference reported by Diff. Op is "added",
+// "removed", "modified" or, with OrderSensitive, "reordered" (Old and New
+// then hold the key order of the map at Path).
+type Change struct {
+	Path string
+	Op   string
+	Old  any
+	New  any
+}
+
+// DiffOption configures Diff.
+type DiffOption func(*diffConfig)
+
+type diffConfig struct {
+	orderSensitive bool
+}
+
+// OrderSensitive makes Diff also report maps whose keys are the same but
+// in a different order.
+func OrderSensitive() DiffOption {
+	return func(c *diffConfig) { c.orderSensitive = true }
+}
+
+// Diff compares two documents and returns their differences, path by path,
+// using the same paths as Leaves ("order/item[1]/@id"). A single element
+// and a list are compared as lists, so adding a second <item> reports
+// "item[1]" as added. Key order is ignored unless OrderSensitive is given.
+func Diff(a, b *OrderedMap, opts ...DiffOption) []Change {
+	cfg := &diffConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	var changes []Change
+	diffValues("", a, b, cfg, &changes)
+	return changes
+}
+
+// diffValues appends the differences between a and b found at path.
+func diffValues(path string, a, b any, cfg *diffConfig, changes *[]Change) {
+	child := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "/" + key
+	}
+
+	aKeys, aGet, aIsMap := diffMapView(a)
+	bKeys, bGet, bIsMap := diffMapView(b)
+	_, aIsList := a.([]any)
+	_, bIsList := b.([]any)
+
+	switch {
+	case aIsMap && bIsMap:
+		for _, k := range aKeys {
+			if bv := bGet(k); bv == nil {
+				*changes = append(*changes, Change{Path: child(k), Op: "removed", Old: aGet(k)})
+			} else {
+				diffValues(child(k), aGet(k), bv, cfg, changes)
+			}
+		}
+		for _, k := range bKeys {
+			if aGet(k) == nil {
+				*changes = append(*changes, Change{Path: child(k), Op: "added", New: bGet(k)})
+			}
+		}
+		if cfg.orderSensitive && !reflect.DeepEqual(diffCommonOrder(aKeys, bGet), diffCommonOrder(bKeys, aGet)) {
+			*changes = append(*changes, Change{Path: path, Op: "reordered", Old: aKeys, New: bKeys})
+		}
+	case aIsList || bIsList:
+		aList, bList := AsSlice(a), AsSlice(b)
+		for i := 0; i < len(aList) || i < len(bList); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(bList):
+				*changes = append(*changes, Change{Path: itemPath, Op: "removed", Old: aList[i]})
+			case i >= len(aList):
+				*changes = append(*changes, Change{Path: itemPath, Op: "added", New: bList[i]})
+			default:
+				diffValues(itemPath, aList[i], bList[i], cfg, changes)
+			}
+		}
+	case !reflect.DeepEqual(a, b):
+		*changes = append(*changes, Change{Path: path, Op: "modified", Old: a, New: b})
+	}
+}
+
+// diffMapView exposes *OrderedMap and map[string]any alike (plain maps in
+// sorted key order).
+func diffMapView(v any) ([]string, func(string) any, bool) {
+	switch m := v.(type) {
+	case *OrderedMap:
+		return m.Keys(), m.Get, true
+	case map[string]any:
+		return sortedKeys(m), func(k string) any { return m[k] }, true
+	}
+	return nil, nil, false
+}
+
+// diffCommonOrder returns keys without those missing from the other map, so
+// that additions and removals alone do not count as reordering.
+func diffCommonOrder(keys []string, otherGet func(string) any) []string {
+	common := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if otherGet(k) != nil {
+			common = append(common, k)
+		}
+	}
+	return common
+}
+
+// ---------------------------------------------------------
+// 8. Namespace Expansion
+// ---------------------------------------------------------
+
+// ExpandNamespaces returns a copy of data with every prefixed element and
+// attribute key expanded to Clark notation, "{namespaceURI}local", so that
+// documents compare equal (e.g. with Diff) whatever prefixes they use:
+// "soap:Envelope" becomes "{http://schemas.xmlsoap.org/soap/envelope/}Envelope".
+//
+// Prefixes are resolved against the "@xmlns:p" / "@xmlns" declarations in
+// the document, as they would be in XML, falling back to declaredNS
+// (prefix -> URI, "" for the default namespace) for prefixes declared
+// outside data, such as those of a MapXML parse with RegisterNamespace.
+// Unprefixed elements take the default namespace; unprefixed attributes
+// have none. The declarations themselves are dropped, keys with unknown
+// prefixes are kept as they are and values are not modified. Anything
+// other than a map yields an empty map.
+//
+// Usage:
+//
+//	a := xml.ExpandNamespaces(docA, nil) // <soap:Envelope xmlns:soap="...">
+//	b := xml.ExpandNamespaces(docB, nil) // <s:Envelope xmlns:s="...">
+//	changes := xml.Diff(a, b)            // Empty if only the prefixes differ
+func ExpandNamespaces(data any, declaredNS map[string]string) *OrderedMap {
+	scope := map[string]string{"xml": xmlNamespaceURL}
+	for prefix, uri := range declaredNS {
+		scope[prefix] = uri
+	}
+	if out, ok := expandNode(data, scope).(*OrderedMap); ok {
+		return out
+	}
+	return NewMap()
+}
+
+// expandNode returns a copy of v with its keys expanded (see
+// ExpandNamespaces). scope maps the prefixes declared around v to their
+// URIs.
+func expandNode(v any, scope map[string]string) any {
+	keys, get, ok := diffMapView(v)
+	if !ok {
+		if list, isList := v.([]any); isList {
+			out := make([]any, len(list))
+			for i, item := range list {
+				out[i] = expandNode(item, scope)
+			}
+			return out
+		}
+		return v
+	}
+
+	out := NewMap()
+	for _, k := range keys {
+		val := get(k)
+		switch {
+		case k == "@xmlns" || strings.HasPrefix(k, "@xmlns:"):
+			// Declarations only matter for resolving the names
+		case strings.HasPrefix(k, "@"):
+			out.Put("@"+expandName(k[1:], scope, true), val)
+		case k == "#seq":
+			// Entries are keyed like the children of this element
+			out.Put(k, expandNode(val, scope))
+		case strings.HasPrefix(k, "#"):
+			out.Put(k, val)
+		default:
+			// Each occurrence of a repeated element may declare its own
+			// namespaces, and so get its own name.
+			var items []any
+			switch list := val.(type) {
+			case []any:
+				items = list
+			case []*OrderedMap:
+				for _, item := range list {
+					items = append(items, item)
+				}
+			default:
+				childScope := namespaceScope(scope, val)
+				name := expandName(k, childScope, false)
+				if out.Has(name) {
+					// Another key (a:x next to b:x on the same URI)
+					// already expanded to this name
+					out.Put(name, append(expandedList(out.Get(name)), expandNode(val, childScope)))
+				} else {
+					out.Put(name, expandNode(val, childScope))
+				}
+				continue
+			}
+			for _, item := range items {
+				childScope := namespaceScope(scope, item)
+				name := expandName(k, childScope, false)
+				out.Put(name, append(expandedList(out.Get(name)), expandNode(item, childScope)))
+			}
+		}
+	}
+	return out
+}
+
+// expandedList returns the value already stored under an expanded name as
+// a list, so that further occurrences can be appended to it.
+func expandedList(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+// namespaceScope returns scope with the "@xmlns" / "@xmlns:p" declarations
+// of the element value v added.
+func namespaceScope(scope map[string]string, v any) map[string]string {
+	keys, get, ok := diffMapView(v)
+	if !ok {
+		return scope
+	}
+	var inner map[string]string
+	for _, k := range keys {
+		prefix, isDecl := "", k == "@xmlns"
+		if p, found := strings.CutPrefix(k, "@xmlns:"); found {
+			prefix, isDecl = p, true
+		}
+		if !isDecl {
+			continue
+		}
+		if inner == nil {
+			inner = make(map[string]string, len(scope)+1)
+			for p, uri := range scope {
+				inner[p] = uri
+			}
+		}
+		inner[prefix] = AsString(get(k))
+	}
+	if inner == nil {
+		return scope
+	}
+	return inner
+}
+
+// expandName writes an element or attribute name in Clark notation when
+// its prefix (or, for elements, the default namespace) is in scope.
+func expandName(name string, scope map[string]string, isAttr bool) string {
+	if strings.HasPrefix(name, "{") {
+		return name // Already expanded
+	}
+	prefix, local, found := strings.Cut(name, ":")
+	if !found {
+		if isAttr {
+			return name
+		}
+		prefix, local = "", name
+	}
+	if uri := scope[prefix]; uri != "" {
+		return "{" + uri + "}" + local
+	}
+	return name
+}
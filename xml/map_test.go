@@ -3,6 +3,7 @@ package xml
 import (
 	"encoding/json"
 	"encoding/xml"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -34,6 +35,76 @@ func TestOrderedMap_Order(t *testing.T) {
 	}
 }
 
+func TestOrderedMap_PutFront(t *testing.T) {
+	m := NewMap()
+	m.Put("cbc:ID", "1")
+	m.Put("cac:Party", "x")
+	m.PutFront("@xmlns", "urn:inv")
+
+	if got := strings.Join(m.Keys(), ","); got != "@xmlns,cbc:ID,cac:Party" {
+		t.Errorf("Keys() = %s, want @xmlns first", got)
+	}
+
+	// Existing key is moved, not duplicated
+	m.PutFront("cac:Party", "y")
+	if got := strings.Join(m.Keys(), ","); got != "cac:Party,@xmlns,cbc:ID" {
+		t.Errorf("Keys() after moving = %s", got)
+	}
+	if m.Len() != 3 || m.Get("cac:Party") != "y" {
+		t.Errorf("Len = %d, cac:Party = %v; want 3, y", m.Len(), m.Get("cac:Party"))
+	}
+}
+
+func TestOrderedMap_Pairs(t *testing.T) {
+	m := NewMap()
+	m.Put("Z", 1)
+	m.Put("A", "two")
+	m.Put("C", 3.5)
+
+	pairs := m.Pairs()
+	want := []Pair{{"Z", 1}, {"A", "two"}, {"C", 3.5}}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("Pairs() = %v, want %v", pairs, want)
+	}
+
+	back := FromPairs(pairs)
+	if got := strings.Join(back.Keys(), ","); got != "Z,A,C" {
+		t.Errorf("FromPairs order = %s, want Z,A,C", got)
+	}
+	if !reflect.DeepEqual(back.Pairs(), want) {
+		t.Errorf("round-trip = %v, want %v", back.Pairs(), want)
+	}
+
+	// Repeated key: first position, last value
+	dup := FromPairs([]Pair{{"a", 1}, {"b", 2}, {"a", 3}})
+	if got := strings.Join(dup.Keys(), ","); got != "a,b" || dup.Get("a") != 3 {
+		t.Errorf("FromPairs with duplicate = %s a=%v, want a,b a=3", got, dup.Get("a"))
+	}
+}
+
+func TestOrderedMap_Attributes(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<Reference URI="#body" Id="r1"><DigestValue>abc</DigestValue></Reference>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	ref := m.GetNode("Reference")
+
+	if got := strings.Join(ref.AttributeKeys(), ","); got != "URI,Id" {
+		t.Errorf("AttributeKeys() = %s, want URI,Id", got)
+	}
+	want := map[string]any{"URI": "#body", "Id": "r1"}
+	if got := ref.Attributes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Attributes() = %v, want %v", got, want)
+	}
+
+	if keys := NewMap().AttributeKeys(); len(keys) != 0 {
+		t.Errorf("AttributeKeys() of an empty map = %v", keys)
+	}
+	if attrs := NewMap().Attributes(); attrs == nil || len(attrs) != 0 {
+		t.Errorf("Attributes() of an empty map = %#v, want an empty map", attrs)
+	}
+}
+
 func TestOrderedMap_DeepSet(t *testing.T) {
 	m := NewMap()
 	m.Set("a/b/c", "deep")
@@ -146,3 +217,246 @@ func TestOrderedMap_Dump(t *testing.T) {
 		t.Error("Dump missing content")
 	}
 }
+
+func TestOrderedMap_Leaves(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<order id="7"><customer><name>Ana</name><vip>true</vip></customer><item><sku>A1</sku></item><item><sku>B2</sku><qty>3</qty></item></order>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	got := map[string]any{}
+	m.Leaves(func(path string, value any) bool {
+		got[path] = value
+		return true
+	})
+	want := map[string]any{
+		"order/@id":           "7",
+		"order/customer/name": "Ana",
+		"order/customer/vip":  "true",
+		"order/item[0]/sku":   "A1",
+		"order/item[1]/sku":   "B2",
+		"order/item[1]/qty":   "3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Leaves = %v, want %v", got, want)
+	}
+
+	// Stops when the callback returns false
+	n := 0
+	m.Leaves(func(string, any) bool { n++; return n < 2 })
+	if n != 2 {
+		t.Errorf("Leaves visited %d leaves after stop, want 2", n)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a, _ := MapXML(strings.NewReader(`<order id="1"><status>new</status><item><sku>A</sku></item><note>x</note></order>`))
+	b, _ := MapXML(strings.NewReader(`<order id="1"><item><sku>B</sku></item><item><sku>C</sku></item><status>paid</status><total>10</total></order>`))
+
+	got := Diff(a, b)
+	want := []Change{
+		{Path: "order/status", Op: "modified", Old: "new", New: "paid"},
+		{Path: "order/item[0]/sku", Op: "modified", Old: "A", New: "B"},
+		{Path: "order/item[1]", Op: "added", New: b.GetNode("order").Get("item").([]any)[1]},
+		{Path: "order/note", Op: "removed", Old: "x"},
+		{Path: "order/total", Op: "added", New: "10"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Same content in a different order is equal unless OrderSensitive
+	c, _ := MapXML(strings.NewReader(`<r><x>1</x><y>2</y></r>`))
+	d, _ := MapXML(strings.NewReader(`<r><y>2</y><x>1</x></r>`))
+	if changes := Diff(c, d); len(changes) != 0 {
+		t.Errorf("reordering reported without OrderSensitive: %+v", changes)
+	}
+	changes := Diff(c, d, OrderSensitive())
+	if len(changes) != 1 || changes[0].Op != "reordered" || changes[0].Path != "r" {
+		t.Errorf("Diff(OrderSensitive) = %+v, want one reordered change at r", changes)
+	}
+	if changes := Diff(c, c, OrderSensitive()); len(changes) != 0 {
+		t.Errorf("Diff of identical maps = %+v", changes)
+	}
+}
+
+func TestOrderedMap_Prune(t *testing.T) {
+	build := func() *OrderedMap {
+		m := NewMap()
+		m.Set("Invoice/ID", "1")
+		m.Set("Invoice/Notes", NewMap())
+		m.Set("Invoice/Party/Address/Lines", []any{})
+		m.Set("Invoice/Party/Name", "ACME")
+		m.Set("Invoice/Party/@ref", "")
+		m.Set("Invoice/Item", []any{NewMap().Set("sku", "A"), NewMap(), NewMap().Set("Ext", NewMap())})
+		m.Set("Invoice/Empty/Deeper/Deepest", map[string]any{"x": []any{}})
+		return m
+	}
+
+	m := build()
+	m.Prune()
+	got, _ := Marshal(m)
+	want := `<Invoice><ID>1</ID><Party ref=""><Name>ACME</Name></Party><Item><sku>A</sku></Item></Invoice>`
+	if got != want {
+		t.Errorf("Prune() =\n%s\nwant\n%s", got, want)
+	}
+	if items, ok := m.GetPath("Invoice/Item").([]any); !ok || len(items) != 1 {
+		t.Errorf("Item = %#v, want a single remaining item", m.GetPath("Invoice/Item"))
+	}
+
+	m = build()
+	m.Prune(PruneEmptyStrings())
+	if m.GetNode("Invoice/Party").Has("@ref") {
+		t.Error("PruneEmptyStrings should remove the empty @ref")
+	}
+	if m.String("Invoice/Party/Name") != "ACME" {
+		t.Error("non-empty siblings must remain")
+	}
+
+	empty := NewMap()
+	empty.Set("a/b", NewMap())
+	empty.Prune()
+	if empty.Len() != 0 {
+		t.Errorf("fully empty tree kept keys %v", empty.Keys())
+	}
+}
+
+func TestMergeAt(t *testing.T) {
+	doc, err := MapXML(strings.NewReader(`<Invoice><ID>1</ID><Lines><Line><sku>A</sku></Line></Lines><Ext><Note>keep</Note></Ext></Invoice>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	// A map fragment merges into the existing node
+	sig := NewMap()
+	sig.Set("@Id", "sig-1")
+	sig.Set("SignatureValue", "abc")
+	if err := MergeAt(doc, "Invoice/Ext", NewMap().Set("Signature", sig)); err != nil {
+		t.Fatalf("MergeAt(Ext) failed: %v", err)
+	}
+	// A list is appended as repeated elements
+	line := NewMap().Set("sku", "B")
+	if err := MergeAt(doc, "Invoice/Lines/Line", []any{line}); err != nil {
+		t.Fatalf("MergeAt(Lines) failed: %v", err)
+	}
+	// Missing intermediate nodes are created
+	if err := MergeAt(doc, "Invoice/Meta/Source", "erp"); err != nil {
+		t.Fatalf("MergeAt(Meta) failed: %v", err)
+	}
+
+	got, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<Invoice><ID>1</ID><Lines><Line><sku>A</sku></Line><Line><sku>B</sku></Line></Lines>` +
+		`<Ext><Note>keep</Note><Signature Id="sig-1"><SignatureValue>abc</SignatureValue></Signature></Ext>` +
+		`<Meta><Source>erp</Source></Meta></Invoice>`
+	if got != want {
+		t.Errorf("merged document =\n%s\nwant\n%s", got, want)
+	}
+
+	// Attributes are replaced, not repeated
+	if err := MergeAt(doc, "", NewMap().Set("Invoice/@version", "2")); err != nil {
+		t.Fatalf("MergeAt(root) failed: %v", err)
+	}
+	if err := MergeAt(doc, "Invoice/@version", "3"); err != nil {
+		t.Fatalf("MergeAt(@version) failed: %v", err)
+	}
+	if v := doc.String("Invoice/@version"); v != "3" {
+		t.Errorf("@version = %q, want 3", v)
+	}
+
+	// A leaf in the middle of the path is an error, not overwritten
+	if err := MergeAt(doc, "Invoice/ID/Extra", "x"); err == nil {
+		t.Error("expected an error merging below a leaf")
+	}
+	if doc.String("Invoice/ID") != "1" {
+		t.Errorf("Invoice/ID was modified: %v", doc.GetPath("Invoice/ID"))
+	}
+}
+
+func TestExpandNamespaces(t *testing.T) {
+	const soapNS = "http://schemas.xmlsoap.org/soap/envelope/"
+
+	// Prefixes declared in the document
+	body := NewMap()
+	body.Set("m:GetPrice/@soap:mustUnderstand", "1")
+	body.Set("m:GetPrice/@id", "2")
+	body.Set("m:GetPrice/m:Item", []any{"A", "B"})
+	body.Set("m:GetPrice/Note", "n")
+	env := NewMap()
+	env.Put("@xmlns:soap", soapNS)
+	env.Put("@xmlns:m", "urn:m")
+	env.Put("soap:Body", body)
+	doc := NewMap()
+	doc.Put("soap:Envelope", env)
+
+	got := ExpandNamespaces(doc, nil)
+	b, err := got.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	want := `{"{` + soapNS + `}Envelope":{"{` + soapNS + `}Body":{"{urn:m}GetPrice":{"@{` + soapNS + `}mustUnderstand":"1","@id":"2","{urn:m}Item":["A","B"],"Note":"n"}}}}`
+	if string(b) != want {
+		t.Errorf("ExpandNamespaces:\ngot  %s\nwant %s", b, want)
+	}
+
+	// The same document with other prefixes and a default namespace (undone
+	// for Note) expands to the same keys
+	other := NewMap()
+	other.Set("s:Envelope/@xmlns:s", soapNS)
+	other.Set("s:Envelope/s:Body/GetPrice/@xmlns", "urn:m")
+	other.Set("s:Envelope/s:Body/GetPrice/@s:mustUnderstand", "1")
+	other.Set("s:Envelope/s:Body/GetPrice/@id", "2")
+	other.Set("s:Envelope/s:Body/GetPrice/Item", []any{"A", "B"})
+	other.Set("s:Envelope/s:Body/GetPrice/Note/@xmlns", "")
+	other.Set("s:Envelope/s:Body/GetPrice/Note/#text", "n")
+	want = strings.Replace(want, `"Note":"n"`, `"Note":{"#text":"n"}`, 1)
+	if b, _ := ExpandNamespaces(other, nil).MarshalJSON(); string(b) != want {
+		t.Errorf("ExpandNamespaces with other prefixes:\ngot  %s\nwant %s", b, want)
+	}
+
+	// Prefixes declared outside the map, e.g. by RegisterNamespace
+	parsed, err := MapXML(strings.NewReader(`<soap:Envelope xmlns:soap="`+soapNS+`"><soap:Body><GetPrice xmlns="urn:m"><Item>A</Item></GetPrice></soap:Body></soap:Envelope>`),
+		RegisterNamespace("soap", soapNS))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	expanded := ExpandNamespaces(parsed, map[string]string{"soap": soapNS})
+	// The parser keeps xmlns:soap as a plain "@soap" attribute
+	b, _ = expanded.MarshalJSON()
+	want = `{"{` + soapNS + `}Envelope":{"@soap":"` + soapNS + `","{` + soapNS + `}Body":{"{urn:m}GetPrice":{"{urn:m}Item":"A"}}}}`
+	if string(b) != want {
+		t.Errorf("ExpandNamespaces with declaredNS:\ngot  %s\nwant %s", b, want)
+	}
+	if ExpandNamespaces("text", nil).Len() != 0 {
+		t.Error("expected an empty map for a non-map value")
+	}
+
+	// Keys that expand to the same name are merged, not overwritten
+	twoPrefixes := NewMap()
+	twoPrefixes.Set("r/@xmlns:a", "urn:x")
+	twoPrefixes.Set("r/@xmlns:b", "urn:x")
+	twoPrefixes.Set("r/a:x", "1")
+	twoPrefixes.Set("r/b:x", "2")
+	defaultNS := NewMap()
+	defaultNS.Set("r/@xmlns", "urn:x")
+	defaultNS.Set("r/@xmlns:p", "urn:x")
+	defaultNS.Set("r/x", "1")
+	defaultNS.Set("r/p:x", []any{"2", "3"})
+	tests := []struct {
+		name string
+		doc  *OrderedMap
+		want string
+	}{
+		{"two prefixes, one URI", twoPrefixes, `{"r":{"{urn:x}x":["1","2"]}}`},
+		{"default namespace next to a prefix", defaultNS, `{"{urn:x}r":{"{urn:x}x":["1","2","3"]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if b, _ := ExpandNamespaces(tt.doc, nil).MarshalJSON(); string(b) != tt.want {
+				t.Errorf("got  %s\nwant %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package xml
 
 import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -64,3 +69,658 @@ func TestParserEncoder_Attributes(t *testing.T) {
 		t.Errorf("Attribute placement error.\nExpected: %s\nGot:      %s", expected, out)
 	}
 }
+
+func TestParserEncoder_XMLNamespaceAttributes(t *testing.T) {
+	inputXML := `<p xml:lang="en" lang="fr" xml:space="preserve">hi</p>`
+
+	m, err := MapXML(strings.NewReader(inputXML))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	if got := m.String("p/@xml:lang"); got != "en" {
+		t.Errorf("@xml:lang = %q, want en", got)
+	}
+	if got := m.String("p/@lang"); got != "fr" {
+		t.Errorf("@lang = %q, want fr", got)
+	}
+	if got := m.String("p/@xml:space"); got != "preserve" {
+		t.Errorf("@xml:space = %q, want preserve", got)
+	}
+
+	// The reserved prefix needs no declaration on the way back
+	out, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if out != inputXML {
+		t.Errorf("Roundtrip mismatch.\nInput:  %s\nOutput: %s", inputXML, out)
+	}
+}
+
+func TestParserEncoder_PreserveCommentOrder(t *testing.T) {
+	inputXML := `<a><!--c1--><b></b><!--c2--></a>`
+
+	om, err := MapXML(strings.NewReader(inputXML), PreserveCommentOrder())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	seq, ok := om.GetPath("a/#seq").([]any)
+	if !ok {
+		t.Fatalf("Expected a/#seq to be a slice, got %T", om.GetPath("a/#seq"))
+	}
+
+	want := []string{"#comment", "b", "#comment"}
+	if len(seq) != len(want) {
+		t.Fatalf("Expected %d #seq entries, got %d: %v", len(want), len(seq), seq)
+	}
+	for i, item := range seq {
+		entry := item.(*OrderedMap)
+		if entry.Keys()[0] != want[i] {
+			t.Errorf("#seq[%d] key = %q, want %q", i, entry.Keys()[0], want[i])
+		}
+	}
+	if seq[0].(*OrderedMap).Get("#comment") != "c1" || seq[2].(*OrderedMap).Get("#comment") != "c2" {
+		t.Errorf("Comment text mismatch: %v", seq)
+	}
+
+	outXML, err := Marshal(om)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if outXML != inputXML {
+		t.Errorf("Roundtrip mismatch.\nInput:  %s\nOutput: %s", inputXML, outXML)
+	}
+
+	// Without the option comments are discarded and no #seq is recorded.
+	plain, _ := MapXML(strings.NewReader(inputXML))
+	if plain.GetPath("a/#seq") != nil {
+		t.Error("#seq should not be recorded without PreserveCommentOrder")
+	}
+}
+
+func TestParserEncoder_ProcessingInstructionRoundtrip(t *testing.T) {
+	inputXML := `<doc><p>one</p><?page-break before="yes"?><p>two</p></doc>`
+
+	om, err := MapXML(strings.NewReader(`<?xml version="1.0"?>`+inputXML), PreserveCommentOrder())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	seq, _ := om.GetPath("doc/#seq").([]any)
+	if len(seq) != 3 || seq[1].(*OrderedMap).Get("#pi") != `page-break before="yes"` {
+		t.Fatalf("doc/#seq = %v, want the PI between both <p>", seq)
+	}
+	if got := Text(om); got != "onetwo" {
+		t.Errorf("Text() = %q, PI data should not count as text", got)
+	}
+
+	outXML, err := Marshal(om)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if outXML != inputXML {
+		t.Errorf("Roundtrip mismatch.\nInput:  %s\nOutput: %s", inputXML, outXML)
+	}
+}
+
+func TestParserEncoder_DoctypeRoundtrip(t *testing.T) {
+	inputXML := `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd"><html><body>x</body></html>`
+
+	om, err := MapXML(strings.NewReader(inputXML), PreserveCommentOrder())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	if got := om.String("#directive"); !strings.HasPrefix(got, "DOCTYPE html PUBLIC") {
+		t.Errorf("#directive = %q", got)
+	}
+	outXML, err := Marshal(om)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if outXML != inputXML {
+		t.Errorf("Roundtrip mismatch.\nInput:  %s\nOutput: %s", inputXML, outXML)
+	}
+
+	// Structured #doctype
+	tests := []struct {
+		doctype any
+		want    string
+	}{
+		{"html", `<!DOCTYPE html><html></html>`},
+		{NewMap().Set("name", "note").Set("system", "note.dtd"), `<!DOCTYPE note SYSTEM "note.dtd"><html></html>`},
+		{map[string]any{"name": "html", "public": "-//W3C//DTD HTML 4.01//EN"}, `<!DOCTYPE html PUBLIC "-//W3C//DTD HTML 4.01//EN"><html></html>`},
+	}
+	for _, tt := range tests {
+		doc := NewMap()
+		doc.Put("#doctype", tt.doctype)
+		doc.Put("html", NewMap())
+		got, err := Marshal(doc)
+		if err != nil {
+			t.Fatalf("Marshal(%v) failed: %v", tt.doctype, err)
+		}
+		if got != tt.want {
+			t.Errorf("Marshal(#doctype %v) = %s, want %s", tt.doctype, got, tt.want)
+		}
+	}
+
+	// Without the option the DOCTYPE is dropped
+	if plain, _ := MapXML(strings.NewReader(inputXML)); plain.Has("#directive") {
+		t.Error("#directive should not be recorded without PreserveCommentOrder")
+	}
+}
+
+func TestDecoder_Reuse(t *testing.T) {
+	dec := NewDecoder(ForceArray("item"))
+
+	docs := []string{
+		`<list><item>a</item></list>`,
+		`<list><item>b</item></list>`,
+		`<list><item>c</item></list>`,
+	}
+	for i, doc := range docs {
+		m, err := dec.Decode(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("Decode #%d failed: %v", i, err)
+		}
+		items, ok := m.GetPath("list/item").([]any)
+		if !ok || len(items) != 1 {
+			t.Fatalf("Decode #%d: expected ForceArray slice of 1, got %T %v", i, m.GetPath("list/item"), m.GetPath("list/item"))
+		}
+		if want := string(rune('a' + i)); items[0] != want {
+			t.Errorf("Decode #%d: got %v, want %s", i, items[0], want)
+		}
+	}
+}
+
+func TestMapXML_WithAttrDefaults(t *testing.T) {
+	inputXML := `<Invoice><Total currencyID="USD">10</Total><Tax>2</Tax></Invoice>`
+
+	om, err := MapXML(strings.NewReader(inputXML),
+		WithAttrDefaults("Total", map[string]string{"currencyID": "COP"}),
+		WithAttrDefaults("Tax", map[string]string{"currencyID": "COP"}),
+	)
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	// Present attributes win over defaults
+	if got := om.String("Invoice/Total/@currencyID"); got != "USD" {
+		t.Errorf("Total/@currencyID = %q, want USD", got)
+	}
+	// Missing attributes are injected
+	if got := om.String("Invoice/Tax/@currencyID"); got != "COP" {
+		t.Errorf("Tax/@currencyID = %q, want COP", got)
+	}
+	if got := om.String("Invoice/Tax/#text"); got != "2" {
+		t.Errorf("Tax/#text = %q, want 2", got)
+	}
+}
+
+func TestMapXMLRoot(t *testing.T) {
+	m, err := MapXMLRoot(strings.NewReader(`<user id="7"><name>Ana</name></user>`))
+	if err != nil {
+		t.Fatalf("MapXMLRoot failed: %v", err)
+	}
+
+	name, err := Query(m, "name")
+	if err != nil || name != "Ana" {
+		t.Errorf("Query(name) = %v, %v; want Ana", name, err)
+	}
+	if m.String("@id") != "7" {
+		t.Errorf("@id = %q, want 7", m.String("@id"))
+	}
+
+	// Text-only root
+	m, err = MapXMLRoot(strings.NewReader(`<greeting>hello</greeting>`))
+	if err != nil {
+		t.Fatalf("MapXMLRoot failed: %v", err)
+	}
+	if m.String("#text") != "hello" {
+		t.Errorf("#text = %q, want hello", m.String("#text"))
+	}
+}
+
+func TestMapXML_NormalizeLineEndings(t *testing.T) {
+	input := "<note><body>line1\r\nline2&#13;&#10;line3&#13;line4</body></note>"
+
+	m, err := MapXML(strings.NewReader(input), NormalizeLineEndings())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	want := "line1\nline2\nline3\nline4"
+	if got := m.String("note/body"); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+
+	// Without the option, character-referenced CRs are kept as-is.
+	m, _ = MapXML(strings.NewReader(input))
+	if got := m.String("note/body"); !strings.Contains(got, "\r") {
+		t.Errorf("body = %q, expected a raw \\r without NormalizeLineEndings", got)
+	}
+}
+
+func TestMapXML_SimplifyIgnoringAttrs(t *testing.T) {
+	input := `<item><price currency="COP">1000</price><tag id="x"/><box size="L"><w>2</w></box></item>`
+
+	m, err := MapXML(strings.NewReader(input), SimplifyIgnoringAttrs())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	if got := m.Get("item").(*OrderedMap).Get("price"); got != "1000" {
+		t.Errorf("price = %#v, want \"1000\"", got)
+	}
+	// No text to collapse to: attributes are kept
+	if got := m.String("item/tag/@id"); got != "x" {
+		t.Errorf("tag/@id = %q, want x", got)
+	}
+	// Child elements: not a text node
+	if got := m.String("item/box/@size"); got != "L" {
+		t.Errorf("box/@size = %q, want L", got)
+	}
+
+	// Default behavior keeps the attribute
+	m, _ = MapXML(strings.NewReader(input))
+	if got := m.String("item/price/@currency"); got != "COP" {
+		t.Errorf("default price/@currency = %q, want COP", got)
+	}
+}
+
+func TestMapXML_GroupAttributes(t *testing.T) {
+	input := `<library><book id="1" lang="en"><title>Go</title></book><tag/></library>`
+
+	m, err := MapXML(strings.NewReader(input), GroupAttributes("_attrs"))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	book := m.GetNode("library/book")
+	if book == nil {
+		t.Fatal("library/book missing")
+	}
+	if book.Has("@id") || book.Has("@lang") {
+		t.Errorf("attributes should not stay as @ siblings: %v", book.Keys())
+	}
+	attrs, ok := book.Get("_attrs").(*OrderedMap)
+	if !ok {
+		t.Fatalf("_attrs = %#v, want *OrderedMap", book.Get("_attrs"))
+	}
+	if got := attrs.Keys(); !reflect.DeepEqual(got, []string{"id", "lang"}) {
+		t.Errorf("_attrs keys = %v, want [id lang]", got)
+	}
+	if attrs.String("id") != "1" || attrs.String("lang") != "en" {
+		t.Errorf("_attrs = id:%q lang:%q, want 1/en", attrs.String("id"), attrs.String("lang"))
+	}
+	if book.String("title") != "Go" {
+		t.Errorf("title = %q, want Go", book.String("title"))
+	}
+}
+
+func TestSplitAttributes(t *testing.T) {
+	input := `<div class="card  featured main"><a rel="nofollow noopener" data-tags="x,y,,z" href="/p">P</a></div>`
+
+	m, err := MapXML(strings.NewReader(input),
+		SplitAttributes(map[string]string{"class": " ", "rel": " ", "data-tags": ","}))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	div := m.GetNode("div")
+	if got := div.Get("@class"); !reflect.DeepEqual(got, []any{"card", "featured", "main"}) {
+		t.Errorf("@class = %#v, want 3 tokens", got)
+	}
+	a := m.GetNode("div/a")
+	if got := a.Get("@rel"); !reflect.DeepEqual(got, []any{"nofollow", "noopener"}) {
+		t.Errorf("@rel = %#v", got)
+	}
+	if got := a.Get("@data-tags"); !reflect.DeepEqual(got, []any{"x", "y", "z"}) {
+		t.Errorf("@data-tags = %#v", got)
+	}
+	if got := a.Get("@href"); got != "/p" {
+		t.Errorf("@href = %#v, want untouched string", got)
+	}
+
+	// Token lists are written back space-separated
+	out, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(out, `class="card featured main"`) {
+		t.Errorf("encoded class = %s", out)
+	}
+}
+
+// BenchmarkMapXML parses 1000 attribute-less text elements, the common
+// shape of data-heavy documents. Run with -benchmem to track allocations.
+func BenchmarkMapXML(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("<root>")
+	for i := 0; i < 1000; i++ {
+		sb.WriteString("<item>Data</item>")
+	}
+	sb.WriteString("</root>")
+	input := sb.String()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := MapXML(strings.NewReader(input)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMapXML_LazyLeafMaps(t *testing.T) {
+	// Bare leaves skip the intermediate map; elements that later gain
+	// children, comments or PIs must still look exactly as before.
+	m, err := MapXML(strings.NewReader(`<r><a>1</a><b/><p>Hi<i>x</i>there</p><c>x<!--n-->y</c></r>`), PreserveCommentOrder())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	r := m.GetNode("r")
+	if got := r.Get("a"); got != "1" {
+		t.Errorf("a = %#v, want \"1\"", got)
+	}
+	if b, ok := r.Get("b").(*OrderedMap); !ok || b.Len() != 0 {
+		t.Errorf("b = %#v, want empty map", r.Get("b"))
+	}
+	p := m.GetNode("r/p")
+	if got := p.Keys(); !reflect.DeepEqual(got, []string{"#text", "i"}) {
+		t.Errorf("p keys = %v, want [#text i]", got)
+	}
+	if got := p.Get("#text"); got != "Hithere" {
+		t.Errorf("p #text = %#v", got)
+	}
+	c := m.GetNode("r/c")
+	if got := c.Get("#text"); got != "xy" || len(c.Get("#seq").([]any)) != 3 {
+		t.Errorf("c = #text %#v, #seq %v", got, c.Get("#seq"))
+	}
+}
+
+func TestWithRawNodes(t *testing.T) {
+	input := `<root><a>x</a><b/><c></c><d id="1">y</d><list><i>1</i><i>2</i></list></root>`
+
+	m, err := MapXML(strings.NewReader(input), WithRawNodes())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	root := m.GetNode("root")
+	for _, k := range []string{"a", "b", "c", "d", "list"} {
+		if _, ok := root.Get(k).(*OrderedMap); !ok {
+			t.Errorf("%s = %#v, want *OrderedMap", k, root.Get(k))
+		}
+	}
+	if got := m.GetPath("root/a/#text"); got != "x" {
+		t.Errorf("a/#text = %#v, want \"x\"", got)
+	}
+	if b := root.GetNode("b"); b.Len() != 0 {
+		t.Errorf("b = %v, want empty map", b.Keys())
+	}
+	items := m.GetNode("root/list").Get("i").([]any)
+	if _, ok := items[1].(*OrderedMap); !ok || len(items) != 2 {
+		t.Errorf("list items = %#v", items)
+	}
+
+	// Type inference applies to #text
+	m, _ = MapXML(strings.NewReader(`<r><n>42</n></r>`), WithRawNodes(), EnableExperimental())
+	if got := m.GetPath("r/n/#text"); got != 42 {
+		t.Errorf("n/#text = %#v, want 42", got)
+	}
+
+	// The raw tree encodes back to the same document
+	m, _ = MapXML(strings.NewReader(input), WithRawNodes())
+	if out, _ := Marshal(m); out != `<root><a>x</a><b></b><c></c><d id="1">y</d><list><i>1</i><i>2</i></list></root>` {
+		t.Errorf("round-trip = %s", out)
+	}
+}
+
+func TestStringifyLeaves(t *testing.T) {
+	input := `<order id="7" tags="a b"><total>10.5</total><paid>true</paid><qty>3</qty><note/><amount><value>100</value></amount><item><sku>A</sku></item><item><sku>B</sku></item></order>`
+
+	m, err := MapXML(strings.NewReader(input), StringifyLeaves(), EnableExperimental(),
+		WithValueHook("qty", func(s string) any { return len(s) }),
+		SplitAttributes(map[string]string{"tags": " "}),
+		CollapseToChild("amount", "value"))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	// Every element is a map and every leaf a string, at any depth
+	var check func(path string, v any)
+	check = func(path string, v any) {
+		switch node := v.(type) {
+		case *OrderedMap:
+			node.ForEach(func(k string, child any) bool {
+				if strings.HasPrefix(k, "@") || strings.HasPrefix(k, "#") {
+					if _, ok := child.(string); !ok {
+						t.Errorf("%s/%s = %#v, want a string", path, k, child)
+					}
+					return true
+				}
+				check(path+"/"+k, child)
+				return true
+			})
+		case []any:
+			for _, item := range node {
+				check(path, item)
+			}
+		default:
+			t.Errorf("%s = %#v, want *OrderedMap", path, v)
+		}
+	}
+	check("", m.Get("order"))
+
+	for path, want := range map[string]string{
+		"order/total/#text":        "10.5",
+		"order/paid/#text":         "true",
+		"order/qty/#text":          "3",
+		"order/@tags":              "a b",
+		"order/amount/value/#text": "100",
+	} {
+		if got := m.GetPath(path); got != want {
+			t.Errorf("%s = %#v, want %q", path, got, want)
+		}
+	}
+}
+
+func TestCollapseToChild(t *testing.T) {
+	input := `<root><amount><value>100</value></amount><tax><value currency="COP">19</value><rate>0.19</rate></tax><other><v>1</v></other></root>`
+
+	m, err := MapXML(strings.NewReader(input), CollapseToChild("amount", "value"), CollapseToChild("tax", "value"), CollapseToChild("other", "missing"))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	if got, _ := Query(m, "root/amount"); got != "100" {
+		t.Errorf("root/amount = %#v, want \"100\"", got)
+	}
+	// The child replaces the wrapper as-is, attributes and all
+	if got, _ := Query(m, "root/tax/@currency"); got != "COP" {
+		t.Errorf("root/tax/@currency = %#v, want COP", got)
+	}
+	if got, _ := Query(m, "root/other/v"); got != "1" {
+		t.Errorf("element without the child should be kept, got %#v", got)
+	}
+}
+
+func TestMapXML_TextRuns(t *testing.T) {
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{`<a>A&amp;B</a>`, "A&B"},
+		{`<a>A &amp; B</a>`, "A & B"},
+		{`<a>Tom <![CDATA[&]]> Jerry</a>`, "Tom & Jerry"},
+		{`<a>one<![CDATA[ two ]]>three</a>`, "one two three"},
+		{`<a>x<!-- note -->y</a>`, "xy"},
+		{"<a>\n  padded &lt;tag&gt;  \n</a>", "padded <tag>"},
+	}
+	for _, tt := range tests {
+		m, err := MapXML(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("MapXML(%q) failed: %v", tt.input, err)
+		}
+		if got := m.Get("a"); got != tt.want {
+			t.Errorf("MapXML(%q) a = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMapXML_AttributeValueNormalization(t *testing.T) {
+	data := "<a title=\" one  two\tthree\r\nfour\nfive \" tags=\"x\ty\"/>"
+	m, err := MapXML(strings.NewReader(data), NormalizeAttributeValues(), SplitAttributes(map[string]string{"tags": ","}))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	if got, want := m.String("a/@title"), " one  two three four five "; got != want {
+		t.Errorf("@title = %q, want %q", got, want)
+	}
+	// Split after normalization: "x y" has no comma, so it stays one token
+	if tags, _ := m.Get("a").(*OrderedMap).Get("@tags").([]any); len(tags) != 1 || tags[0] != "x y" {
+		t.Errorf("@tags = %q, want [x y]", tags)
+	}
+
+	// Off by default: the decoded value is kept verbatim
+	m, _ = MapXML(strings.NewReader(data))
+	if got := m.String("a/@title"); got != " one  two\tthree\nfour\nfive " {
+		t.Errorf("default @title = %q", got)
+	}
+}
+
+func TestSetDefaultOptions(t *testing.T) {
+	SetDefaultOptions(ForceArray("item"), GroupAttributes("_attrs"))
+	defer SetDefaultOptions()
+
+	const data = `<list><item id="7">x</item></list>`
+	m, err := MapXML(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	items, ok := m.Get("list").(*OrderedMap).Get("item").([]any)
+	if !ok || len(items) != 1 {
+		t.Fatalf("item = %#v, want a one-element []any from the default options", m.Get("list").(*OrderedMap).Get("item"))
+	}
+	if got := items[0].(*OrderedMap).String("_attrs/id"); got != "7" {
+		t.Errorf("_attrs/id = %q, want 7", got)
+	}
+
+	// Per-call options are applied last
+	m, _ = MapXML(strings.NewReader(data), GroupAttributes("meta"))
+	items, _ = m.Get("list").(*OrderedMap).Get("item").([]any)
+	if len(items) != 1 || items[0].(*OrderedMap).String("meta/id") != "7" {
+		t.Errorf("per-call GroupAttributes did not override the default: %v", m)
+	}
+
+	SetDefaultOptions()
+	m, _ = MapXML(strings.NewReader(data))
+	if _, ok := m.Get("list").(*OrderedMap).Get("item").([]any); ok {
+		t.Error("defaults still applied after SetDefaultOptions()")
+	}
+}
+
+func TestPreserveCDATA(t *testing.T) {
+	data := `<doc><script><![CDATA[if (a < b) { x(); }]]></script><note>a &lt; b</note>` +
+		"<mixed>pre <![CDATA[<b>]]> post</mixed><spaced>\n  <![CDATA[ keep ]]>\n</spaced><n><![CDATA[42]]></n></doc>"
+
+	m, err := MapXML(strings.NewReader(data), PreserveCDATA())
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	tests := []struct {
+		path string
+		want any
+	}{
+		{"doc/script/#cdata", "if (a < b) { x(); }"},
+		{"doc/note", "a < b"},
+		{"doc/mixed", "pre <b> post"}, // Mixed runs are plain text
+		{"doc/spaced/#cdata", " keep "},
+		{"doc/n/#cdata", "42"}, // No type inference
+	}
+	for _, tt := range tests {
+		if got := m.GetPath(tt.path); got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.path, got, tt.want)
+		}
+	}
+
+	out, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<doc><script><![CDATA[if (a < b) { x(); }]]></script><note>a &lt; b</note>` +
+		`<mixed>pre &lt;b&gt; post</mixed><spaced><![CDATA[ keep ]]></spaced><n><![CDATA[42]]></n></doc>`
+	if out != want {
+		t.Errorf("round-trip:\ngot  %s\nwant %s", out, want)
+	}
+
+	// Without the option CDATA content is plain text
+	m, _ = MapXML(strings.NewReader(data))
+	if got := m.GetPath("doc/script"); got != "if (a < b) { x(); }" {
+		t.Errorf("default script = %#v", got)
+	}
+}
+
+func TestEncoder_CDATATerminator(t *testing.T) {
+	m := NewMap()
+	m.Set("a/#cdata", "x]]>y")
+	out, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	back, err := MapXML(strings.NewReader(out), PreserveCDATA())
+	if err != nil {
+		t.Fatalf("MapXML(%s) failed: %v", out, err)
+	}
+	if got := back.GetPath("a/#cdata"); got != "x]]>y" {
+		t.Errorf("round-trip of %s = %#v, want x]]>y", out, got)
+	}
+}
+
+// openFDs counts the file descriptors open in this process (Linux only).
+func openFDs(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("/proc/self/fd not available")
+	}
+	return len(entries)
+}
+
+func TestMapXMLFile(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "order.xml")
+	if err := os.WriteFile(plain, []byte(`<order id="7"><item>A</item></order>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`<order id="8"><item>B</item></order>`))
+	zw.Close()
+	compressed := filepath.Join(dir, "order.xml.gz")
+	if err := os.WriteFile(compressed, gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	before := openFDs(t)
+	tests := []struct {
+		path, id, item string
+	}{
+		{plain, "7", "A"},
+		{compressed, "8", "B"},
+	}
+	for _, tt := range tests {
+		m, err := MapXMLFile(tt.path, ForceArray("item"))
+		if err != nil {
+			t.Fatalf("MapXMLFile(%s) failed: %v", tt.path, err)
+		}
+		if m.String("order/@id") != tt.id {
+			t.Errorf("MapXMLFile(%s) = %v", tt.path, m)
+		}
+		if items, _ := m.GetNode("order").Get("item").([]any); len(items) != 1 || items[0] != tt.item {
+			t.Errorf("MapXMLFile(%s) item = %v, want [%s]", tt.path, items, tt.item)
+		}
+	}
+	if after := openFDs(t); after > before {
+		t.Errorf("open file descriptors: %d before, %d after; the file was not closed", before, after)
+	}
+
+	if _, err := MapXMLFile(filepath.Join(dir, "missing.xml")); !os.IsNotExist(err) {
+		t.Errorf("missing file error = %v, want a not-exist error", err)
+	}
+}
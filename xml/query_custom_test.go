@@ -57,6 +57,22 @@ func TestQuery_CustomFunction(t *testing.T) {
 			path:     "invoice/items/func:startsWithBox/sku",
 			expected: []string{"SKU-BOX-L", "SKU-BOX-S"},
 		},
+		// Glob segments (no registration needed)
+		{
+			name:     "glob prefix box_*",
+			path:     "invoice/items/box_*/sku",
+			expected: []string{"SKU-BOX-L", "SKU-BOX-S"},
+		},
+		{
+			name:     "glob suffix *_case",
+			path:     "invoice/items/*_case/id",
+			expected: []string{"snake"},
+		},
+		{
+			name:     "glob infix b*e",
+			path:     "invoice/items/b*e/sku",
+			expected: []string{"SKU-BOX-L"},
+		},
 		// 1. isNumeric
 		{
 			name:     "isNumeric",
@@ -1,6 +1,9 @@
 package xml
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -180,6 +183,78 @@ func TestQueryAll_DeepSearch(t *testing.T) {
 	}
 }
 
+func TestQueryAll_SubNodeAnchoring(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<Envelope><Body><Resp>
+		<code>0</code>
+		<item><code>A</code><meta><code>A-1</code></meta></item>
+		<item><code>B</code></item>
+	</Resp><code>outside</code></Body></Envelope>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	resp, err := Query(m, "Envelope/Body/Resp")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want []any
+	}{
+		{"code", []any{"0"}},
+		{"/code", []any{"0"}}, // anchored at the sub-node, not the document
+		{"//code", []any{"0", "A", "A-1", "B"}},
+		{"item//code", []any{"A", "A-1", "B"}},
+		{"/item/meta//code", []any{"A-1"}},
+		{"//meta", []any{m.GetNode("Envelope/Body/Resp").List("item")[0].Get("meta")}},
+	}
+	for _, tt := range tests {
+		got, err := QueryAll(resp, tt.path)
+		if err != nil {
+			t.Fatalf("QueryAll(%q) error: %v", tt.path, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryAll(sub, %q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestQueryAll_Union(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<store>
+		<book><title>Go</title><author>Ana</author></book>
+		<book><title>A|B</title><author>Luis</author></book>
+	</store>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want []any
+	}{
+		{"store/book/title | store/book/author", []any{"Go", "A|B", "Ana", "Luis"}},
+		{"store/book[title='A|B']/author|store/missing", []any{"Luis"}},
+		{"store/book[author='Ana'] | store/book[title='Go']", []any{m.GetNode("store").List("book")[0]}}, // no duplicates
+		{"store/missing | store/nothing", nil},
+	}
+	for _, tt := range tests {
+		got, err := QueryAll(m, tt.path)
+		if err != nil {
+			t.Fatalf("QueryAll(%q) error: %v", tt.path, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryAll(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := QueryAll(m, "store/book/title | store/missing", WithStrictPath()); err != nil {
+		t.Errorf("strict union with one matching path failed: %v", err)
+	}
+	if _, err := QueryAll(m, "store/missing | store/nothing", WithStrictPath()); err == nil {
+		t.Error("strict union with no match should fail")
+	}
+}
+
 func TestQuery_SmartText(t *testing.T) {
 	data := getQueryTestData()
 
@@ -225,3 +300,219 @@ func TestQuery_Errors(t *testing.T) {
 		}
 	}
 }
+
+func TestQuery_AttrFallback(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<user id="42"><name>Ana</name></user>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	// Default: "id" only matches child elements
+	if _, err := Query(m, "user/id"); err == nil {
+		t.Error("Expected not found for user/id without WithAttrFallback")
+	}
+
+	got, err := Query(m, "user/id", WithAttrFallback())
+	if err != nil {
+		t.Fatalf("Query with WithAttrFallback failed: %v", err)
+	}
+	if got != "42" {
+		t.Errorf("user/id = %v, want 42", got)
+	}
+
+	// Child elements still take precedence
+	if got, _ := Query(m, "user/name", WithAttrFallback()); got != "Ana" {
+		t.Errorf("user/name = %v, want Ana", got)
+	}
+}
+
+func TestQuery_FilterTrimsWhitespace(t *testing.T) {
+	// As left by a whitespace-preserving parse
+	m, err := MapXML(strings.NewReader(`<lib>
+		<book><author>Cervantes</author><title>Quijote</title></book>
+		<book><author>Borges</author><title>Ficciones</title></book>
+	</lib>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	m.List("lib/book")[0].Put("author", " Cervantes ")
+
+	tests := []struct {
+		path string
+		opts []QueryOption
+		want int
+	}{
+		{"lib/book[author='Cervantes']/title", nil, 1},
+		{"lib/book[author=' Borges ']/title", nil, 1},
+		{"lib/book[author!='Cervantes']/title", nil, 1},
+		{"lib/book[author='Cervantes']/title", []QueryOption{WithExactMatch()}, 0},
+		{"lib/book[author=' Cervantes ']/title", []QueryOption{WithExactMatch()}, 1},
+	}
+	for _, tt := range tests {
+		got, err := QueryAll(m, tt.path, tt.opts...)
+		if err != nil {
+			t.Fatalf("QueryAll(%q) failed: %v", tt.path, err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("QueryAll(%q, exact=%v) = %v, want %d results", tt.path, tt.opts != nil, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_FilterOnComplexChild(t *testing.T) {
+	// Fixture: library/description = {"#text": "A place for books", "@lang": "en"}
+	data := getQueryTestData()
+	info, err := Query(data, "library[description='A place for books']/info")
+	if err != nil || info != "City Library" {
+		t.Errorf("Filter on complex child = %v, %v; want City Library", info, err)
+	}
+
+	m, err := MapXML(strings.NewReader(`<shelf>
+		<book><author role="lead">Einstein</author><title>Relativity</title></book>
+		<book><author role="lead">Curie</author><title>Radioactivity</title></book>
+	</shelf>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	title, err := Query(m, "shelf/book[author='Einstein']/title")
+	if err != nil || title != "Relativity" {
+		t.Errorf("shelf/book[author='Einstein']/title = %v, %v; want Relativity", title, err)
+	}
+	title, err = Query(m, "shelf/book[starts-with(author, 'Cu')]/title")
+	if err != nil || title != "Radioactivity" {
+		t.Errorf("starts-with on complex child = %v, %v; want Radioactivity", title, err)
+	}
+}
+
+func TestQueryMap(t *testing.T) {
+	data := getQueryTestData()
+	prices, err := QueryMap(data, "//price", func(v any) string {
+		return fmt.Sprintf("$%v.00", v)
+	})
+	if err != nil {
+		t.Fatalf("QueryMap failed: %v", err)
+	}
+	want := []string{"$50.00", "$30.00"}
+	if !reflect.DeepEqual(prices, want) {
+		t.Errorf("QueryMap(//price) = %v, want %v", prices, want)
+	}
+
+	missing, err := QueryMap(data, "library/nothing", func(v any) int { return 1 })
+	if err != nil || len(missing) != 0 {
+		t.Errorf("QueryMap on missing path = %v, %v; want empty", missing, err)
+	}
+}
+
+func TestQueryNodeAndQueryString(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<users>
+		<user><name>Ana</name></user>
+		<user><name lang="es">Luis</name></user>
+	</users>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"users/user[0]/name", "Ana"},  // simplified to a string by the parser
+		{"users/user[1]/name", "Luis"}, // kept as a node because of @lang
+	} {
+		node, err := QueryNode(m, tt.path)
+		if err != nil {
+			t.Fatalf("QueryNode(%q) error: %v", tt.path, err)
+		}
+		if got := node.String("#text"); got != tt.want {
+			t.Errorf("QueryNode(%q)/#text = %q, want %q", tt.path, got, tt.want)
+		}
+
+		s, err := QueryString(m, tt.path)
+		if err != nil || s != tt.want {
+			t.Errorf("QueryString(%q) = %q, %v; want %q", tt.path, s, err, tt.want)
+		}
+	}
+
+	if node, _ := QueryNode(m, "users/user[1]/name"); node.String("@lang") != "es" {
+		t.Errorf("QueryNode should keep attributes, got %v", node.Keys())
+	}
+	if _, err := QueryNode(m, "users/user"); err == nil {
+		t.Error("QueryNode on a list should fail")
+	}
+	if _, err := QueryString(m, "users/admin"); err == nil {
+		t.Error("QueryString on a missing path should fail")
+	}
+}
+
+func TestTransform(t *testing.T) {
+	resp, err := MapXML(strings.NewReader(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+		<soap:Body>
+			<GetOrderResponse>
+				<Order id="A-1">
+					<Customer><Name>Ana</Name><City>Bogota</City></Customer>
+					<Total currency="COP">1000</Total>
+				</Order>
+			</GetOrderResponse>
+		</soap:Body>
+	</soap:Envelope>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	tmpl := NewMap()
+	tmpl.Set("Summary/@order", "{{Envelope/Body/GetOrderResponse/Order/@id}}")
+	tmpl.Set("Summary/Customer", "{{Envelope/Body/GetOrderResponse/Order/Customer/Name}}")
+	tmpl.Set("Summary/Amount", "{{ Envelope/Body/GetOrderResponse/Order/Total }} {{Envelope/Body/GetOrderResponse/Order/Total/@currency}}")
+	tmpl.Set("Summary/Source", "erp")
+
+	out, err := Transform(resp, tmpl)
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	xmlStr, _ := Marshal(out)
+	want := `<Summary order="A-1"><Customer>Ana</Customer><Amount>1000 COP</Amount><Source>erp</Source></Summary>`
+	if xmlStr != want {
+		t.Errorf("Transform output:\n got: %s\nwant: %s", xmlStr, want)
+	}
+
+	// The template is left untouched
+	if tmpl.String("Summary/Source") != "erp" || tmpl.String("Summary/Customer") == "Ana" {
+		t.Errorf("template was modified: %s", tmpl.Dump())
+	}
+
+	tmpl.Set("Summary/Missing", "{{Envelope/Body/Nope}}")
+	if _, err := Transform(resp, tmpl); err == nil || !strings.Contains(err.Error(), "Envelope/Body/Nope") {
+		t.Errorf("Transform with a missing path = %v, want error naming the path", err)
+	}
+}
+
+func TestQuery_StrictPath(t *testing.T) {
+	data := getQueryTestData()
+
+	// Lenient (default): empty result, no error
+	res, err := QueryAll(data, "library/section/magazine/title")
+	if err != nil || res != nil {
+		t.Errorf("lenient QueryAll = %v, %v; want nil, nil", res, err)
+	}
+
+	for _, tt := range []struct {
+		path, segment string
+	}{
+		{"archive/section/book", "'archive' (1 of 3)"},                 // wrong root
+		{"library/section/magazine/title", "'magazine' (3 of 4)"},      // missing intermediate
+		{"library/section/book[price>100]/title", "'book[price>100]'"}, // filter drops everything
+		{"//magazine/title", "deep search 'magazine'"},
+	} {
+		_, err := QueryAll(data, tt.path, WithStrictPath())
+		if err == nil || !strings.Contains(err.Error(), tt.segment) {
+			t.Errorf("strict QueryAll(%q) error = %v, want it to name %s", tt.path, err, tt.segment)
+		}
+		if _, err := Query(data, tt.path, WithStrictPath()); err == nil || !strings.Contains(err.Error(), tt.segment) {
+			t.Errorf("strict Query(%q) error = %v, want it to name %s", tt.path, err, tt.segment)
+		}
+	}
+
+	// A valid path is unaffected
+	if v, err := Query(data, "library/info", WithStrictPath()); err != nil || v != "City Library" {
+		t.Errorf("strict Query(library/info) = %v, %v", v, err)
+	}
+}
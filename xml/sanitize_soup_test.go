@@ -4,6 +4,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSanitizeSoup(t *testing.T) {
@@ -127,3 +128,96 @@ func TestSanitizeSoup_EdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestSoupMode_RepairsMismatchedTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		path  string
+		want  string
+	}{
+		{"unclosed inline element", `<p><b>Hello</p>`, "p/b", "Hello"},
+		{"stray closing tag", `<div><b>text</i> after</div>`, "div/b", "text after"},
+		{"unclosed at EOF", `<ul><li>one<li>two`, "ul/li/li", "two"},
+		{"void element", `<p>a<br>b</p>`, "p/#text", "ab"},
+		{"siblings after repair", `<div><span>x</div><p>y</p>`, "div/span", "x"},
+	}
+	for _, tt := range tests {
+		done := make(chan struct{})
+		var m *OrderedMap
+		var err error
+		go func() {
+			defer close(done)
+			m, err = MapXML(strings.NewReader("<root>"+tt.input+"</root>"), EnableExperimental())
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: MapXML did not return", tt.name)
+		}
+		if err != nil {
+			t.Errorf("%s: MapXML error: %v", tt.name, err)
+			continue
+		}
+		if got := m.String("root/" + tt.path); got != tt.want {
+			t.Errorf("%s: %s = %q, want %q (%s)", tt.name, tt.path, got, tt.want, m.Dump())
+		}
+	}
+}
+
+func TestSoupMode_TextJoiner(t *testing.T) {
+	html := `<div><p>One<br>Two <b>bold</b> Three</p><span>a<br>b</span></div>`
+
+	tests := []struct {
+		name   string
+		joiner func(prev, next string) string
+		p      string
+		span   string
+	}{
+		{"default", nil, "OneTwoThree", "ab"},
+		{"space", func(prev, next string) string { return prev + " " + next }, "One Two Three", "a b"},
+		{"newline", func(prev, next string) string { return prev + "\n" + next }, "One\nTwo\nThree", "a\nb"},
+	}
+	for _, tt := range tests {
+		opts := []Option{EnableExperimental()}
+		if tt.joiner != nil {
+			opts = append(opts, WithTextJoiner(tt.joiner))
+		}
+		m, err := MapXML(strings.NewReader(html), opts...)
+		if err != nil {
+			t.Fatalf("%s: MapXML failed: %v", tt.name, err)
+		}
+		if got := m.String("div/p/#text"); got != tt.p {
+			t.Errorf("%s: p text = %q, want %q", tt.name, got, tt.p)
+		}
+		if got := m.String("div/span/#text"); got != tt.span {
+			t.Errorf("%s: span text = %q, want %q", tt.name, got, tt.span)
+		}
+	}
+}
+
+func TestSoupMode_ResolvesNamespaces(t *testing.T) {
+	const svgNS = "http://www.w3.org/2000/svg"
+	html := `<html xml:lang="es"><body>
+		<svg:svg xmlns:svg="` + svgNS + `"><svg:rect width="10"/><svg:g><svg:circle r="1"></svg:g></svg:svg>
+		<math xmlns="urn:math"><mi>x</mi></math>
+		<p>after<br>x</p>
+	</body></html>`
+
+	m, err := MapXML(strings.NewReader(html), EnableExperimental(),
+		RegisterNamespace("svg", svgNS), RegisterNamespace("m", "urn:math"))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	for path, want := range map[string]string{
+		"html/@xml:lang":                        "es",
+		"html/body/svg:svg/svg:rect/@width":     "10",
+		"html/body/svg:svg/svg:g/svg:circle/@r": "1",
+		"html/body/m:math/m:mi":                 "x",
+		"html/body/p/#text":                     "afterx",
+	} {
+		if got := m.String(path); got != want {
+			t.Errorf("%s = %q, want %q (%s)", path, got, want, m.Dump())
+		}
+	}
+}
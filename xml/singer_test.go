@@ -1,10 +1,16 @@
 package xml
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/pem"
 	"math/big"
 	"strings"
@@ -141,6 +147,165 @@ func TestCreateXadesSignature(t *testing.T) {
 	}
 }
 
+// The document digest must match an independent enveloped-signature
+// transform + Exclusive C14N + SHA-256 of the content, even when xmlContent
+// is not in canonical form and already carries a ds:Signature.
+func TestCreateSignature_DigestMatchesEnvelopedC14N(t *testing.T) {
+	certPEM, keyPEM := generateTestKeys(t)
+	s, _ := NewSigner(certPEM, keyPEM)
+
+	xmlContent := []byte(`<root b="2" a="1"><data>hello</data><ds:Signature xmlns:ds="` + dsigNS + `"><ds:SignatureValue>old</ds:SignatureValue></ds:Signature><empty/></root>`)
+
+	sigMap, err := s.CreateSignature(xmlContent)
+	if err != nil {
+		t.Fatalf("CreateSignature failed: %v", err)
+	}
+
+	canon, err := CanonicalizeXML([]byte(`<root a="1" b="2"><data>hello</data><empty></empty></root>`))
+	if err != nil {
+		t.Fatalf("CanonicalizeXML failed: %v", err)
+	}
+	sum := sha256.Sum256(canon)
+	want := base64.StdEncoding.EncodeToString(sum[:])
+
+	got := sigMap.String("ds:SignedInfo/ds:Reference/ds:DigestValue")
+	if got != want {
+		t.Errorf("DigestValue = %s, want %s (C14N of %s)", got, want, canon)
+	}
+}
+
+func TestCreateSignature_ExternalReference(t *testing.T) {
+	certPEM, keyPEM := generateTestKeys(t)
+	s, _ := NewSigner(certPEM, keyPEM)
+
+	doc, inner := buildSignableDoc(t)
+	preSignBytes, _ := Marshal(doc)
+
+	attachment := sha256.Sum256([]byte("attachment bytes"))
+	sig, err := s.CreateSignature([]byte(preSignBytes), ExternalReference{URI: "document.xml", Digest: attachment[:]})
+	if err != nil {
+		t.Fatalf("CreateSignature error: %v", err)
+	}
+
+	refs, ok := sig.Get("ds:SignedInfo").(*OrderedMap).Get("ds:Reference").([]*OrderedMap)
+	if !ok || len(refs) != 2 {
+		t.Fatalf("ds:Reference = %#v, want 2 references", sig.Get("ds:SignedInfo").(*OrderedMap).Get("ds:Reference"))
+	}
+	ext := refs[1]
+	if ext.String("@URI") != "document.xml" {
+		t.Errorf("external @URI = %q, want document.xml", ext.String("@URI"))
+	}
+	if got, want := ext.String("ds:DigestValue"), base64.StdEncoding.EncodeToString(attachment[:]); got != want {
+		t.Errorf("external DigestValue = %q, want %q", got, want)
+	}
+	if got := ext.String("ds:DigestMethod/@Algorithm"); got != "http://www.w3.org/2001/04/xmlenc#sha256" {
+		t.Errorf("external DigestMethod = %q, want sha256 default", got)
+	}
+
+	// The detached reference is signed but not fetched: it is reported and
+	// only accepted on request
+	inner.Set("ds:Signature", sig)
+	finalXML, _ := Marshal(doc)
+	if err := s.Verify([]byte(finalXML)); err == nil || !strings.Contains(err.Error(), "document.xml") {
+		t.Errorf("Verify with an external reference = %v, want a detached reference error", err)
+	}
+	if err := s.Verify([]byte(finalXML), AllowDetachedReferences()); err != nil {
+		t.Errorf("Verify failed with AllowDetachedReferences: %v", err)
+	}
+
+	res, err := VerifySignature([]byte(finalXML))
+	if err != nil {
+		t.Fatalf("VerifySignature error: %v", err)
+	}
+	if res.Valid() || !res.DigestsMatch || !res.SignatureValid || len(res.Unverified) != 1 || res.Unverified[0] != "document.xml" {
+		t.Errorf("VerifySignature = %+v, want invalid with document.xml unverified", res)
+	}
+	if res, _ := VerifySignature([]byte(finalXML), AllowDetachedReferences()); !res.Valid() {
+		t.Errorf("VerifySignature with AllowDetachedReferences = %+v, want valid", res)
+	}
+}
+
+func TestStripSignature(t *testing.T) {
+	certPEM, keyPEM := generateTestKeys(t)
+	s, _ := NewSigner(certPEM, keyPEM)
+
+	doc, inner := buildSignableDoc(t)
+	preSign, _ := Marshal(doc)
+	sig, err := s.CreateSignature([]byte(preSign))
+	if err != nil {
+		t.Fatalf("CreateSignature error: %v", err)
+	}
+	inner.Set("ds:Signature", sig)
+	inner.Set("Ext/Signature", "unprefixed")
+
+	signedXML, _ := Marshal(doc)
+	parsed, err := MapXML(strings.NewReader(signedXML), RegisterNamespace("ds", dsigNS))
+	if err != nil {
+		t.Fatalf("MapXML error: %v", err)
+	}
+
+	clean := StripSignature(parsed)
+	if clean.GetPath("Root/ds:Signature") != nil {
+		t.Errorf("signature still present: %s", clean.Dump())
+	}
+	// Not an XML-DSig signature
+	if clean.String("Root/Ext/Signature") != "unprefixed" {
+		t.Errorf("unrelated Signature removed: %s", clean.Dump())
+	}
+	if clean.String("Root/ID") != "SETT-100" || clean.String("Root/Amount") != "1000.00" {
+		t.Errorf("document content lost: %s", clean.Dump())
+	}
+	// The input is left untouched
+	if parsed.GetPath("Root/ds:Signature") == nil {
+		t.Error("StripSignature modified its input")
+	}
+}
+
+func TestStripSignature_KeepsUBLSignature(t *testing.T) {
+	invoice := `<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+		xmlns:cac="urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+		xmlns:cbc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+		xmlns:ext="urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2"
+		xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+		<ext:UBLExtensions><ext:UBLExtension><ext:ExtensionContent>
+			<ds:Signature Id="xmldsig-1"><ds:SignedInfo><ds:Reference URI=""/></ds:SignedInfo><ds:SignatureValue>abc</ds:SignatureValue></ds:Signature>
+		</ext:ExtensionContent></ext:UBLExtension></ext:UBLExtensions>
+		<cbc:ID>SETP990000002</cbc:ID>
+		<cac:Signature><cbc:ID>SIG-1</cbc:ID><cac:SignatoryParty><cbc:Name>ACME</cbc:Name></cac:SignatoryParty></cac:Signature>
+	</Invoice>`
+
+	tests := []struct {
+		name string
+		opts []Option
+		ds   string // Path of the XML-DSig signature
+		cac  string // Path of the UBL signature
+	}{
+		{"prefixes kept", []Option{
+			RegisterNamespace("ds", dsigNS),
+			RegisterNamespace("cac", "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"),
+			RegisterNamespace("cbc", "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"),
+			RegisterNamespace("ext", "urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2"),
+		}, "Invoice/ext:UBLExtensions/ext:UBLExtension/ext:ExtensionContent/ds:Signature", "Invoice/cac:Signature/cbc:ID"},
+		{"prefixes dropped", nil, "Invoice/UBLExtensions/UBLExtension/ExtensionContent/Signature", "Invoice/Signature/ID"},
+	}
+	for _, tt := range tests {
+		parsed, err := MapXML(strings.NewReader(invoice), tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: MapXML error: %v", tt.name, err)
+		}
+		if parsed.GetPath(tt.ds) == nil {
+			t.Fatalf("%s: fixture has no signature at %s: %s", tt.name, tt.ds, parsed.Dump())
+		}
+		clean := StripSignature(parsed)
+		if clean.GetPath(tt.ds) != nil {
+			t.Errorf("%s: ds:Signature still present: %s", tt.name, clean.Dump())
+		}
+		if clean.String(tt.cac) != "SIG-1" {
+			t.Errorf("%s: cac:Signature removed: %s", tt.name, clean.Dump())
+		}
+	}
+}
+
 // buildSignableDoc mirrors the pattern demo.go uses: build the document,
 // marshal it BEFORE the signature exists (that's what gets referenced), sign
 // it, embed the returned signature, and re-marshal.
@@ -209,6 +374,46 @@ func TestSigner_CreateXadesSignature_VerifyRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSigner_InclusiveNamespaces_VerifyRoundTrip(t *testing.T) {
+	certPEM, keyPEM := generateTestKeys(t)
+	s, _ := NewSigner(certPEM, keyPEM)
+	s.InclusiveNamespaces = []string{"cbc"}
+
+	doc, inner := buildSignableDoc(t)
+	inner.Set("@xmlns:cbc", "urn:cbc")
+	inner.Set("Note", "cbc:Unused") // cbc only appears in text content
+	preSignBytes, _ := Marshal(doc)
+
+	sig, err := s.CreateXadesSignature([]byte(preSignBytes))
+	if err != nil {
+		t.Fatalf("CreateXadesSignature error: %v", err)
+	}
+	refs := sig.GetNode("ds:SignedInfo").List("ds:Reference")
+	transforms := refs[0].List("ds:Transforms/ds:Transform")
+	if len(transforms) != 2 || transforms[1].String("ec:InclusiveNamespaces/@PrefixList") != "cbc" {
+		t.Errorf("document transforms = %v, want an exc-c14n transform with PrefixList cbc", transforms)
+	}
+
+	// The digest covers the xmlns:cbc declaration
+	canon, _ := CanonicalizeXML([]byte(preSignBytes), WithInclusiveNamespaces("cbc"))
+	sum := sha256.Sum256(canon)
+	if got, want := refs[0].String("ds:DigestValue"), base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("DigestValue = %s, want %s (C14N of %s)", got, want, canon)
+	}
+
+	inner.Set("ds:Signature", sig)
+	finalXML, _ := Marshal(doc)
+	if err := s.Verify([]byte(finalXML)); err != nil {
+		t.Fatalf("Verify failed with a PrefixList: %v\nXML: %s", err, finalXML)
+	}
+
+	// The declaration is signed: changing the cbc namespace breaks it
+	tampered := strings.Replace(finalXML, `xmlns:cbc="urn:cbc"`, `xmlns:cbc="urn:other"`, 1)
+	if err := s.Verify([]byte(tampered)); err == nil {
+		t.Error("expected Verify to fail after changing an inclusive namespace")
+	}
+}
+
 // tamperFirstChar replaces the first character of the text content of the
 // first <tag>...</tag> found in xmlStr with a different, still-valid
 // character, guaranteeing the content changes without altering its length.
@@ -280,3 +485,199 @@ func TestSigner_Verify_DetectsTamperedSignatureValue(t *testing.T) {
 		t.Errorf("expected a signature mismatch error, got: %v", err)
 	}
 }
+
+func TestVerifySignature(t *testing.T) {
+	certPEM, keyPEM := generateTestKeys(t)
+	s, _ := NewSigner(certPEM, keyPEM)
+
+	doc, inner := buildSignableDoc(t)
+	preSignBytes, _ := Marshal(doc)
+	sig, err := s.CreateXadesSignature([]byte(preSignBytes))
+	if err != nil {
+		t.Fatalf("CreateXadesSignature error: %v", err)
+	}
+	inner.Set("ds:Signature", sig)
+	finalXML, _ := Marshal(doc)
+
+	res, err := VerifySignature([]byte(finalXML))
+	if err != nil {
+		t.Fatalf("VerifySignature error: %v", err)
+	}
+	if !res.Valid() || !res.DigestsMatch || !res.SignatureValid {
+		t.Errorf("VerifySignature = %+v, want valid", res)
+	}
+	if res.Subject != "CN=Test Cert,O=Test Org" || res.Certificate == nil {
+		t.Errorf("Subject = %q, want CN=Test Cert,O=Test Org", res.Subject)
+	}
+
+	// A tampered document: the document digest fails, the signature over
+	// SignedInfo is still intact
+	res, err = VerifySignature([]byte(tamperFirstChar(t, finalXML, "ID")))
+	if err != nil {
+		t.Fatalf("VerifySignature (tampered) error: %v", err)
+	}
+	if res.Valid() || res.DigestsMatch || !res.SignatureValid || len(res.Mismatched) != 1 || res.Mismatched[0] != "" {
+		t.Errorf("VerifySignature (tampered) = %+v, want only the URI=\"\" digest to fail", res)
+	}
+
+	if _, err := VerifySignature([]byte(preSignBytes)); err == nil {
+		t.Error("VerifySignature on an unsigned document should fail")
+	}
+}
+
+// generateECTestKeys is generateTestKeys with an ECDSA P-256 key, in SEC 1
+// ("EC PRIVATE KEY") PEM form.
+func generateECTestKeys(t *testing.T) ([]byte, []byte) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "EC Test Cert"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("Failed to marshal EC key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+}
+
+func TestSigner_ECDSA_VerifyRoundTrip(t *testing.T) {
+	certPEM, keyPEM := generateECTestKeys(t)
+	s, err := NewSigner(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("NewSigner (EC) failed: %v", err)
+	}
+	if _, ok := s.Key.(*ecdsa.PrivateKey); !ok {
+		t.Fatalf("Key = %T, want *ecdsa.PrivateKey", s.Key)
+	}
+
+	for _, xades := range []bool{false, true} {
+		doc, inner := buildSignableDoc(t)
+		preSignBytes, _ := Marshal(doc)
+		var sig *OrderedMap
+		if xades {
+			sig, err = s.CreateXadesSignature([]byte(preSignBytes))
+		} else {
+			sig, err = s.CreateSignature([]byte(preSignBytes))
+		}
+		if err != nil {
+			t.Fatalf("sign (xades=%v) error: %v", xades, err)
+		}
+		if got := sig.String("ds:SignedInfo/ds:SignatureMethod/@Algorithm"); got != ecdsaSHA256Algorithm {
+			t.Errorf("SignatureMethod = %s, want %s", got, ecdsaSHA256Algorithm)
+		}
+		// XML-DSig ECDSA values are r||s: 64 bytes for P-256
+		if raw, _ := base64.StdEncoding.DecodeString(sig.String("ds:SignatureValue")); len(raw) != 64 {
+			t.Errorf("SignatureValue is %d bytes, want 64", len(raw))
+		}
+
+		inner.Set("ds:Signature", sig)
+		finalXML, _ := Marshal(doc)
+		if err := s.Verify([]byte(finalXML)); err != nil {
+			t.Errorf("Verify (xades=%v) failed: %v", xades, err)
+		}
+		tampered := tamperFirstChar(t, finalXML, "ds:SignatureValue")
+		if res, err := VerifySignature([]byte(tampered)); err != nil || res.SignatureValid {
+			t.Errorf("tampered ECDSA signature accepted (xades=%v): %+v, %v", xades, res, err)
+		}
+	}
+}
+
+func TestNewSigner_UnsupportedKey(t *testing.T) {
+	certPEM, _ := generateTestKeys(t)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	_, err := NewSigner(certPEM, keyPEM)
+	if err == nil || !strings.Contains(err.Error(), "unsupported private key type") {
+		t.Errorf("NewSigner(ed25519) error = %v, want an unsupported key type error", err)
+	}
+}
+
+func TestSigner_HashAlgorithms(t *testing.T) {
+	certPEM, keyPEM := generateTestKeys(t)
+	ecCertPEM, ecKeyPEM := generateECTestKeys(t)
+
+	tests := []struct {
+		name            string
+		ec              bool
+		opts            SignerOptions
+		digestAlg       string
+		signatureAlg    string
+		digestLen       int
+		wantCreateError bool
+	}{
+		{"default", false, SignerOptions{}, sha256DigestAlgorithm, rsaSHA256Algorithm, 32, false},
+		{"rsa-sha1", false, SignerOptions{crypto.SHA1, crypto.SHA1}, sha1DigestAlgorithm, rsaSHA1Algorithm, 20, false},
+		{"rsa-sha512", false, SignerOptions{crypto.SHA512, crypto.SHA512}, sha512DigestAlgorithm, rsaSHA512Algorithm, 64, false},
+		{"sha1 digest, rsa-sha256", false, SignerOptions{DigestMethod: crypto.SHA1}, sha1DigestAlgorithm, rsaSHA256Algorithm, 20, false},
+		{"ecdsa-sha512", true, SignerOptions{crypto.SHA512, crypto.SHA512}, sha512DigestAlgorithm, ecdsaSHA512Algorithm, 64, false},
+		{"weaker signature", false, SignerOptions{crypto.SHA512, crypto.SHA1}, "", "", 0, true},
+		{"unsupported hash", false, SignerOptions{DigestMethod: crypto.MD5}, "", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		cert, key := certPEM, keyPEM
+		if tt.ec {
+			cert, key = ecCertPEM, ecKeyPEM
+		}
+		s, err := NewSigner(cert, key)
+		if err != nil {
+			t.Fatalf("%s: NewSigner failed: %v", tt.name, err)
+		}
+		s.SignerOptions = tt.opts
+
+		for _, xades := range []bool{false, true} {
+			doc, inner := buildSignableDoc(t)
+			preSignBytes, _ := Marshal(doc)
+			var sig *OrderedMap
+			if xades {
+				sig, err = s.CreateXadesSignature([]byte(preSignBytes))
+			} else {
+				sig, err = s.CreateSignature([]byte(preSignBytes))
+			}
+			if tt.wantCreateError {
+				if err == nil {
+					t.Errorf("%s (xades=%v): expected an error", tt.name, xades)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s (xades=%v): sign error: %v", tt.name, xades, err)
+			}
+
+			if got := sig.String("ds:SignedInfo/ds:SignatureMethod/@Algorithm"); got != tt.signatureAlg {
+				t.Errorf("%s: SignatureMethod = %s, want %s", tt.name, got, tt.signatureAlg)
+			}
+			for _, ref := range sig.List("ds:SignedInfo/ds:Reference") {
+				if got := ref.String("ds:DigestMethod/@Algorithm"); got != tt.digestAlg {
+					t.Errorf("%s: DigestMethod = %s, want %s", tt.name, got, tt.digestAlg)
+				}
+				if raw, _ := base64.StdEncoding.DecodeString(ref.String("ds:DigestValue")); len(raw) != tt.digestLen {
+					t.Errorf("%s: DigestValue is %d bytes, want %d", tt.name, len(raw), tt.digestLen)
+				}
+			}
+
+			inner.Set("ds:Signature", sig)
+			finalXML, _ := Marshal(doc)
+			if err := s.Verify([]byte(finalXML)); err != nil {
+				t.Errorf("%s (xades=%v): Verify failed: %v", tt.name, xades, err)
+			}
+			tampered := tamperFirstChar(t, finalXML, "ID")
+			if res, err := VerifySignature([]byte(tampered)); err != nil || res.DigestsMatch {
+				t.Errorf("%s (xades=%v): tampered document accepted: %+v, %v", tt.name, xades, res, err)
+			}
+		}
+	}
+}
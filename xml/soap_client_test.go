@@ -1,11 +1,14 @@
 package xml
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSoapClient_PayloadOrder(t *testing.T) {
@@ -71,3 +74,192 @@ func TestSoapClient_WSSecurity(t *testing.T) {
 	payload := NewMap()
 	client.Call("Test", payload)
 }
+
+func TestBuildPayload(t *testing.T) {
+	payload := BuildPayload(map[string]string{
+		"User/Id":     "100",
+		"User/Active": "true",
+		"Channel":     "web",
+	})
+
+	if got := payload.Keys(); len(got) != 2 || got[0] != "Channel" || got[1] != "User" {
+		t.Errorf("Top-level keys = %v, want [Channel User]", got)
+	}
+	user := payload.GetNode("User")
+	if user == nil {
+		t.Fatal("User node missing or not an OrderedMap")
+	}
+	if user.String("Id") != "100" {
+		t.Errorf("User/Id = %q, want 100", user.String("Id"))
+	}
+	if user.Get("Active") != true {
+		t.Errorf("User/Active = %#v, want true", user.Get("Active"))
+	}
+
+	s, err := Marshal(NewMap().Set("Request", payload))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `<Request><Channel>web</Channel><User><Active>true</Active><Id>100</Id></User></Request>`
+	if s != expected {
+		t.Errorf("Marshal(payload) = %s, want %s", s, expected)
+	}
+}
+
+func TestSoapClient_UserAgentAndHeaderRemoval(t *testing.T) {
+	var gotUA string
+	var hasSOAPAction, hasUA bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		_, hasUA = r.Header["User-Agent"]
+		_, hasSOAPAction = r.Header["Soapaction"]
+		w.Write([]byte(`<root><ok/></root>`))
+	}))
+	defer ts.Close()
+
+	// Default User-Agent
+	if _, err := NewSoapClient(ts.URL, "ns").Call("Test", nil); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if gotUA != "r2-xml-client/2.0" {
+		t.Errorf("default User-Agent = %q", gotUA)
+	}
+	if !hasSOAPAction {
+		t.Error("SOAPAction header should be sent by default")
+	}
+
+	// Custom User-Agent, SOAPAction removed via an empty header value
+	client := NewSoapClient(ts.URL, "ns", WithUserAgent("acme-erp/3.1"), WithHeader("SOAPAction", ""))
+	if _, err := client.Call("Test", nil); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if gotUA != "acme-erp/3.1" {
+		t.Errorf("User-Agent = %q, want acme-erp/3.1", gotUA)
+	}
+	if hasSOAPAction {
+		t.Error("SOAPAction header should have been removed")
+	}
+
+	// An empty User-Agent header suppresses it entirely
+	client = NewSoapClient(ts.URL, "ns", WithHeader("User-Agent", ""))
+	if _, err := client.Call("Test", nil); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if hasUA {
+		t.Errorf("User-Agent should have been removed, got %q", gotUA)
+	}
+}
+
+func TestSoapClient_WSSecurityTimestamp(t *testing.T) {
+	var captured string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bodyBytes, _ := io.ReadAll(r.Body)
+		captured = string(bodyBytes)
+		w.Write([]byte(`<root><ok/></root>`))
+	}))
+	defer ts.Close()
+
+	ttl := 5 * time.Minute
+	client := NewSoapClient(ts.URL, "ns", WithWSSecurity("user", "pass"), WithWSSecurityTimestamp(ttl))
+	if _, err := client.Call("Test", NewMap()); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	env, err := MapXML(strings.NewReader(captured))
+	if err != nil {
+		t.Fatalf("request is not valid XML: %v\n%s", err, captured)
+	}
+	security := env.GetNode("Envelope/Header/Security")
+	if security == nil {
+		t.Fatalf("missing Security header:\n%s", captured)
+	}
+	if keys := security.Keys(); keys[len(keys)-2] != "Timestamp" || keys[len(keys)-1] != "UsernameToken" {
+		t.Errorf("Security children = %v, want Timestamp before UsernameToken", keys)
+	}
+
+	created, err := time.Parse(time.RFC3339, security.String("Timestamp/Created"))
+	if err != nil {
+		t.Fatalf("invalid Created: %v", err)
+	}
+	expires, err := time.Parse(time.RFC3339, security.String("Timestamp/Expires"))
+	if err != nil {
+		t.Fatalf("invalid Expires: %v", err)
+	}
+	if got := expires.Sub(created); got != ttl {
+		t.Errorf("Expires - Created = %v, want %v", got, ttl)
+	}
+	if !strings.Contains(captured, `wsu:Id="TS-1"`) {
+		t.Error("Timestamp should carry a wsu:Id")
+	}
+}
+
+func TestSoapClient_RequestDump(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<root><ok/></root>`))
+	}))
+	defer ts.Close()
+
+	var dump bytes.Buffer
+	client := NewSoapClient(ts.URL, "http://tempuri.org/", WithRequestDump(&dump))
+	if _, err := client.Call("GetUser", BuildPayload(map[string]string{"id": "1"})); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	out := dump.String()
+	for _, want := range []string{
+		"POST " + ts.URL,
+		`Soapaction: "http://tempuri.org/GetUser"`,
+		`<GetUser xmlns="http://tempuri.org/">`,
+		"<id>1</id>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSoapClient_StreamingRequests(t *testing.T) {
+	const n = 20000
+	var gotItems int
+	var contentLength int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		m, err := MapXML(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		skus, _ := QueryAll(m, "//item/sku")
+		gotItems = len(skus)
+		if gotItems == n && skus[n-1] == fmt.Sprintf("SKU-%d", n-1) {
+			w.Write([]byte(`<root><ok>true</ok></root>`))
+		}
+	}))
+	defer ts.Close()
+
+	items := make([]any, n)
+	for i := range items {
+		item := NewMap()
+		item.Put("sku", fmt.Sprintf("SKU-%d", i))
+		item.Put("qty", i)
+		items[i] = item
+	}
+	payload := NewMap()
+	payload.Put("item", items)
+
+	var dump bytes.Buffer
+	client := NewSoapClient(ts.URL, "http://tempuri.org/", WithStreamingRequests(), WithRequestDump(&dump))
+	resp, err := client.Call("Batch", payload)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if gotItems != n || resp.String("root/ok") != "true" {
+		t.Errorf("server received %d items (ok=%q), want %d intact", gotItems, resp.String("root/ok"), n)
+	}
+	if contentLength != -1 {
+		t.Errorf("ContentLength = %d, want -1 (streamed body)", contentLength)
+	}
+	if !strings.Contains(dump.String(), "<sku>SKU-19999</sku>") {
+		t.Error("request dump is missing the streamed body")
+	}
+}
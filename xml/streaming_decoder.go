@@ -2,9 +2,12 @@ package xml
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 )
 
 // ============================================================================
@@ -17,6 +20,9 @@ import (
 type Stream[T any] struct {
 	decoder *xml.Decoder
 	tagName string
+	closer  io.Closer // Input opened by NewStreamFile, closed when the stream ends
+	path    []string  // When set, only tagName elements at this path match (see newPathStream)
+	open    []string  // Local names of the elements enclosing the decoder position, tracked for path
 }
 
 // NewStream initializes a new streaming iterator for a specific XML tag.
@@ -25,10 +31,7 @@ type Stream[T any] struct {
 // opts: Variadic options (e.g., EnableLegacyCharsets)
 func NewStream[T any](r io.Reader, tagName string, opts ...Option) *Stream[T] {
 	// 1. Process configuration
-	cfg := defaultConfig()
-	for _, opt := range opts {
-		opt(cfg)
-	}
+	cfg := newConfig(opts)
 
 	decoder := xml.NewDecoder(r)
 
@@ -44,6 +47,45 @@ func NewStream[T any](r io.Reader, tagName string, opts ...Option) *Stream[T] {
 	}
 }
 
+// newPathStream is NewStream anchored to a slash-separated element path
+// from the root ("orders/order"): only the last segment's elements found
+// exactly at that path are decoded, as MapXML's List(path) would select
+// them. Segments are compared by local name.
+func newPathStream[T any](r io.Reader, path string, opts ...Option) *Stream[T] {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = localName(seg)
+	}
+	s := NewStream[T](r, segments[len(segments)-1], opts...)
+	s.path = segments[:len(segments)-1]
+	return s
+}
+
+// NewStreamFile is NewStream over the file at path (gzip-compressed files
+// are decompressed transparently). The file is closed once the stream is
+// drained: when the Iter/IterWithContext channel is closed (also on context
+// cancellation) or when EncodeJSON returns.
+//
+//	stream, err := xml.NewStreamFile[Product]("catalog.xml", "product")
+//	if err != nil { ... }
+//	for p := range stream.Iter() { ... }
+func NewStreamFile[T any](path, tagName string, opts ...Option) (*Stream[T], error) {
+	f, err := openXMLFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := NewStream[T](f, tagName, opts...)
+	s.closer = f
+	return s, nil
+}
+
+// close releases the input opened by NewStreamFile.
+func (s *Stream[T]) close() {
+	if s.closer != nil {
+		s.closer.Close()
+	}
+}
+
 // Iter returns a read-only channel of items of type T.
 // It is a convenience wrapper around IterWithContext using context.Background().
 //
@@ -69,6 +111,7 @@ func (s *Stream[T]) IterWithContext(ctx context.Context) <-chan T {
 	ch := make(chan T)
 	go func() {
 		defer close(ch)
+		defer s.close()
 		for {
 			// 1. Check cancellation before work
 			select {
@@ -104,3 +147,204 @@ func (s *Stream[T]) IterWithContext(ctx context.Context) <-chan T {
 	}()
 	return ch
 }
+
+// EncodeJSON drains the stream, writing every decoded item as an element of
+// a single JSON array to w. Items are written as they are decoded, so memory
+// stays flat regardless of the input size. Unlike Iter, decode errors are
+// returned instead of silently ending the stream.
+//
+// Usage:
+//
+//	stream := xml.NewStream[Order](file, "order")
+//	err := stream.EncodeJSON(os.Stdout) // [{"ID":1,...},{"ID":2,...}]
+func (s *Stream[T]) EncodeJSON(w io.Writer) error {
+	defer s.close()
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	for {
+		item, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// next decodes the next tagName element, returning io.EOF at the end of the
+// input.
+func (s *Stream[T]) next() (T, error) {
+	var item T
+	for {
+		t, err := s.decoder.Token()
+		if err == io.EOF {
+			return item, err
+		}
+		if err != nil {
+			return item, wrapError(err)
+		}
+
+		switch se := t.(type) {
+		case xml.StartElement:
+			if se.Name.Local == s.tagName && s.atPath() {
+				if err := s.decoder.DecodeElement(&item, &se); err != nil {
+					return item, wrapError(err)
+				}
+				return item, nil
+			}
+			if s.path != nil {
+				s.open = append(s.open, se.Name.Local)
+			}
+		case xml.EndElement:
+			if s.path != nil && len(s.open) > 0 {
+				s.open = s.open[:len(s.open)-1]
+			}
+		}
+	}
+}
+
+// atPath reports whether the decoder is positioned inside the elements of
+// s.path (always true for an unanchored stream).
+func (s *Stream[T]) atPath() bool {
+	if s.path == nil {
+		return true
+	}
+	return slices.Equal(s.open, s.path)
+}
+
+// WalkHandlers holds the callbacks of Walk. Any of them may be nil. path is
+// the slash-separated element path from the root ("root/items/item"), with
+// the same name resolution as MapXML (namespace aliases, soup lowercasing).
+type WalkHandlers struct {
+	OnStart func(path string, attrs map[string]string)
+	OnText  func(path string, text string) // Trimmed, non-empty text only
+	OnEnd   func(path string)
+}
+
+// Walk parses r as a stream of events, SAX style: every element start, text
+// and end is reported to h as it is read, so mixed documents can be handled
+// in one pass without building the tree. It accepts the same parser Options
+// as MapXML (lenient/soup mode, legacy charsets, namespace aliases...). In
+// soup mode tags are balanced as MapXML does: a closing tag also ends the
+// elements still open inside it, and whatever is open when the input ends
+// (or stops parsing) is ended too.
+//
+// Usage:
+//
+//	err := xml.Walk(file, xml.WalkHandlers{
+//	    OnStart: func(path string, attrs map[string]string) {
+//	        if path == "feed/entry" { ... }
+//	    },
+//	})
+func Walk(r io.Reader, h WalkHandlers, opts ...Option) error {
+	cfg := newConfig(opts)
+	next := newTokenSource(r, cfg, nil)
+
+	var path []byte
+	var lengths []int  // len(path) before each open element
+	var names []string // Name of each open element, for soup mode balancing
+	end := func() {
+		if h.OnEnd != nil {
+			h.OnEnd(string(path))
+		}
+		path = path[:lengths[len(lengths)-1]]
+		lengths = lengths[:len(lengths)-1]
+		names = names[:len(names)-1]
+	}
+	for {
+		token, _, err := next()
+		if err != nil {
+			if err != io.EOF && !cfg.isSoupMode {
+				return wrapError(err)
+			}
+			// Soup mode: decoder errors are final (as in buildMap), so
+			// stop there and end whatever is still open.
+			for len(lengths) > 0 {
+				end()
+			}
+			return nil
+		}
+
+		switch se := token.(type) {
+		case xml.StartElement:
+			localName := se.Name.Local
+			if cfg.isSoupMode {
+				localName = strings.ToLower(localName)
+			}
+			name := resolveName(xml.Name{Space: se.Name.Space, Local: localName}, cfg.namespaces)
+			lengths = append(lengths, len(path))
+			names = append(names, name)
+			if len(path) > 0 {
+				path = append(path, '/')
+			}
+			path = append(path, name...)
+
+			if h.OnStart != nil {
+				var attrs map[string]string
+				if len(se.Attr) > 0 {
+					attrs = make(map[string]string, len(se.Attr))
+					for _, attr := range se.Attr {
+						attrName := attr.Name.Local
+						if cfg.isSoupMode {
+							attrName = strings.ToLower(attrName)
+						}
+						attrs[resolveName(xml.Name{Space: attr.Name.Space, Local: attrName}, cfg.namespaces)] = normalizeAttrValue(attr.Value, cfg)
+					}
+				}
+				h.OnStart(string(path), attrs)
+			}
+			if cfg.isSoupMode && containsFold(cfg.htmlAutoClose, localName) {
+				end()
+			}
+
+		case xml.CharData:
+			if h.OnText == nil || len(lengths) == 0 {
+				continue
+			}
+			content := string(se)
+			if cfg.normalizeEOL {
+				content = normalizeLineEndings(content)
+			}
+			if trimmed := strings.TrimSpace(content); trimmed != "" {
+				h.OnText(string(path), trimmed)
+			}
+
+		case xml.EndElement:
+			if !cfg.isSoupMode {
+				if len(lengths) > 0 {
+					end()
+				}
+				continue
+			}
+			// Tag balancing: close the nearest open element of this name
+			// with any still open inside it; ignore a stray closing tag.
+			name := resolveName(xml.Name{Space: se.Name.Space, Local: strings.ToLower(se.Name.Local)}, cfg.namespaces)
+			for i := len(names) - 1; i >= 0; i-- {
+				if names[i] == name {
+					for len(names) > i {
+						end()
+					}
+					break
+				}
+			}
+		}
+	}
+}
@@ -3,7 +3,10 @@ package xml
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -39,6 +42,60 @@ func TestStream_Iter_Basic(t *testing.T) {
 	}
 }
 
+func TestStream_OrderedMap(t *testing.T) {
+	data := `<root xmlns:g="urn:g">
+		<Item id="1"><g:name>Alice</g:name><tag>a</tag><tag>b</tag></Item>
+		<Item id="2"><name>Bob</name></Item>
+	</root>`
+
+	stream := NewStream[*OrderedMap](strings.NewReader(data), "Item")
+
+	var got []string
+	for m := range stream.Iter() {
+		b, err := m.MarshalJSON()
+		if err != nil {
+			t.Fatalf("MarshalJSON failed: %v", err)
+		}
+		got = append(got, string(b))
+	}
+
+	want := []string{
+		`{"@id":"1","name":"Alice","tag":["a","b"]}`,
+		`{"@id":"2","name":"Bob"}`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNewStreamFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.xml")
+	data := `<root><Item id="1"><name>Alice</name></Item><Item id="2"><name>Bob</name></Item></root>`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	before := openFDs(t)
+	stream, err := NewStreamFile[streamItem](path, "Item")
+	if err != nil {
+		t.Fatalf("NewStreamFile failed: %v", err)
+	}
+	var got []streamItem
+	for item := range stream.Iter() {
+		got = append(got, item)
+	}
+	if len(got) != 2 || got[1].Name != "Bob" {
+		t.Errorf("items = %+v", got)
+	}
+	if after := openFDs(t); after > before {
+		t.Errorf("open file descriptors: %d before, %d after draining; the file was not closed", before, after)
+	}
+
+	if _, err := NewStreamFile[streamItem](path+".missing", "Item"); err == nil {
+		t.Error("NewStreamFile on a missing file should fail")
+	}
+}
+
 func TestStream_IterWithContext_AlreadyCancelled(t *testing.T) {
 	data := `<root><Item id="1"><name>A</name></Item></root>`
 	stream := NewStream[streamItem](strings.NewReader(data), "Item")
@@ -126,3 +183,156 @@ func TestStream_DecodeErrorClosesChannel(t *testing.T) {
 		t.Errorf("expected exactly 1 valid item before the error, got %d: %+v", len(got), got)
 	}
 }
+
+func TestStream_EncodeJSON(t *testing.T) {
+	type order struct {
+		ID    int     `xml:"id,attr" json:"id"`
+		Total float64 `xml:"total" json:"total"`
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<orders>")
+	const total = 50
+	for i := 0; i < total; i++ {
+		fmt.Fprintf(&sb, `<order id="%d"><total>%d.50</total></order>`, i, i)
+	}
+	sb.WriteString("</orders>")
+
+	var out bytes.Buffer
+	stream := NewStream[order](strings.NewReader(sb.String()), "order")
+	if err := stream.EncodeJSON(&out); err != nil {
+		t.Fatalf("EncodeJSON failed: %v", err)
+	}
+
+	var got []order
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("output is not a valid JSON array: %v\n%s", err, out.String())
+	}
+	if len(got) != total {
+		t.Fatalf("expected %d orders, got %d", total, len(got))
+	}
+	if got[7].ID != 7 || got[7].Total != 7.5 {
+		t.Errorf("order 7 = %+v, want {ID:7 Total:7.5}", got[7])
+	}
+
+	// Empty input yields an empty array
+	out.Reset()
+	if err := NewStream[order](strings.NewReader("<orders/>"), "order").EncodeJSON(&out); err != nil {
+		t.Fatalf("EncodeJSON on empty input failed: %v", err)
+	}
+	if out.String() != "[]" {
+		t.Errorf("expected [], got %s", out.String())
+	}
+
+	// Malformed input surfaces an error
+	err := NewStream[order](strings.NewReader(`<orders><order id="1">`), "order").EncodeJSON(&out)
+	if err == nil {
+		t.Error("expected an error for malformed input")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	data := `<root>
+		<meta version="2"/>
+		<items><item id="1">A</item><item id="2">B<b>!</b></item></items>
+	</root>`
+
+	var events []string
+	err := Walk(strings.NewReader(data), WalkHandlers{
+		OnStart: func(path string, attrs map[string]string) {
+			events = append(events, fmt.Sprintf("start %s %v", path, attrs))
+		},
+		OnText: func(path, text string) {
+			events = append(events, "text "+path+" "+text)
+		},
+		OnEnd: func(path string) {
+			events = append(events, "end "+path)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	want := []string{
+		"start root map[]",
+		"start root/meta map[version:2]",
+		"end root/meta",
+		"start root/items map[]",
+		"start root/items/item map[id:1]",
+		"text root/items/item A",
+		"end root/items/item",
+		"start root/items/item map[id:2]",
+		"text root/items/item B",
+		"start root/items/item/b map[]",
+		"text root/items/item/b !",
+		"end root/items/item/b",
+		"end root/items/item",
+		"end root/items",
+		"end root",
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWalk_Options(t *testing.T) {
+	data := `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><BR>x</BR></s:Body></s:Envelope>`
+
+	var paths []string
+	err := Walk(strings.NewReader(data), WalkHandlers{
+		OnStart: func(path string, _ map[string]string) { paths = append(paths, path) },
+	}, RegisterNamespace("soap", "http://schemas.xmlsoap.org/soap/envelope/"))
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if got := strings.Join(paths, ","); got != "soap:Envelope,soap:Envelope/soap:Body,soap:Envelope/soap:Body/BR" {
+		t.Errorf("paths = %s", got)
+	}
+
+	if err := Walk(strings.NewReader(`<a><b></a>`), WalkHandlers{}); err == nil {
+		t.Error("Walk should report malformed input")
+	}
+}
+
+func TestWalk_SoupModeStopsOnError(t *testing.T) {
+	for _, data := range []string{`<div><p>a</b></p></div>`, `<div><p a=>x`} {
+		done := make(chan error, 1)
+		go func() {
+			done <- Walk(strings.NewReader(data), WalkHandlers{}, EnableExperimental())
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Walk(%q) in soup mode = %v, want nil", data, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Walk(%q) in soup mode did not return", data)
+		}
+	}
+}
+
+func TestWalk_SoupModeBalancesTags(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`<p><b>Hello</p>`, "start p,start p/b,text p/b Hello,end p/b,end p"},
+		{`<div><p>a</b></p></div>`, "start div,start div/p,text div/p a,end div/p,end div"},
+		{`<div><br>x<svg:rect xmlns:svg="urn:svg"/></div>`, "start div,start div/br,end div/br,text div x,start div/svg:rect,end div/svg:rect,end div"},
+		{`<ul><li>one`, "start ul,start ul/li,text ul/li one,end ul/li,end ul"},
+	}
+	for _, tt := range tests {
+		var events []string
+		err := Walk(strings.NewReader(tt.data), WalkHandlers{
+			OnStart: func(path string, _ map[string]string) { events = append(events, "start "+path) },
+			OnText:  func(path, text string) { events = append(events, "text "+path+" "+text) },
+			OnEnd:   func(path string) { events = append(events, "end "+path) },
+		}, EnableExperimental(), RegisterNamespace("svg", "urn:svg"))
+		if err != nil {
+			t.Errorf("Walk(%q) failed: %v", tt.data, err)
+		}
+		if got := strings.Join(events, ","); got != tt.want {
+			t.Errorf("Walk(%q):\ngot  %s\nwant %s", tt.data, got, tt.want)
+		}
+	}
+}
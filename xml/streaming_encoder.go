@@ -2,12 +2,16 @@ package xml
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ============================================================================
@@ -16,16 +20,14 @@ import (
 
 // Encoder writes XML directly to an io.Writer.
 type Encoder struct {
-	w   io.Writer
-	cfg *config
+	w       io.Writer
+	cfg     *config
+	started bool // Something was already written (BOM goes only first)
 }
 
 // NewEncoder creates a configured encoder.
 func NewEncoder(w io.Writer, opts ...Option) *Encoder {
-	cfg := defaultConfig()
-	for _, opt := range opts {
-		opt(cfg)
-	}
+	cfg := newConfig(opts)
 	return &Encoder{w: w, cfg: cfg}
 }
 
@@ -59,8 +61,26 @@ func (e *Encoder) Encode(data any) error {
 		return errors.New("root element not found")
 	}
 
+	if e.cfg.writeBOM && !e.started {
+		if _, err := io.WriteString(e.w, "\uFEFF"); err != nil {
+			return err
+		}
+	}
+	e.started = true
+
+	if err := writeDoctype(e.w, valGetter("#directive"), valGetter("#doctype")); err != nil {
+		return err
+	}
+
 	val := valGetter(rootTag)
-	return encodeNode(e.w, rootTag, val, e.cfg, 0)
+	if err := encodeNode(e.w, rootTag, val, e.cfg, 0); err != nil {
+		return err
+	}
+	if e.cfg.finalNewline {
+		_, err := io.WriteString(e.w, "\n")
+		return err
+	}
+	return nil
 }
 
 // Marshal returns the XML as a string (Helper wrapper).
@@ -84,6 +104,7 @@ func encodeNode(w io.Writer, tag string, value any, cfg *config, depth int) erro
 	startElem := "<" + tag
 
 	// Handle Namespaces (only at Root / depth 0)
+	var nsDecls, attrs string
 	if depth == 0 && len(cfg.namespaces) > 0 {
 		var urls []string
 		for u := range cfg.namespaces {
@@ -92,12 +113,13 @@ func encodeNode(w io.Writer, tag string, value any, cfg *config, depth int) erro
 		sort.Strings(urls)
 		for _, u := range urls {
 			alias := cfg.namespaces[u]
-			startElem += fmt.Sprintf(` xmlns:%s="%s"`, alias, u)
+			nsDecls += fmt.Sprintf(` xmlns:%s="%s"`, alias, u)
 		}
 	}
 
 	var content any
 	var cdataContent string
+	var seq []any
 	var childrenKeys []string
 	var childrenValGetter func(string) any
 	var isComplex bool
@@ -112,13 +134,13 @@ func encodeNode(w io.Writer, tag string, value any, cfg *config, depth int) erro
 		// 1. Filter Attributes
 		for _, k := range allKeys {
 			if strings.HasPrefix(k, "@") {
-				val := v.Get(k)
-				esc := escapeString(fmt.Sprintf("%v", val))
-				startElem += fmt.Sprintf(` %s="%s"`, strings.TrimPrefix(k, "@"), esc)
+				attrs += formatAttr(strings.TrimPrefix(k, "@"), v.Get(k), cfg)
 			} else if k == "#text" {
 				content = v.Get(k)
 			} else if k == "#cdata" {
-				cdataContent = fmt.Sprintf("%v", v.Get(k))
+				cdataContent = formatValue(v.Get(k), cfg)
+			} else if k == "#seq" {
+				seq, _ = v.Get(k).([]any)
 			} else {
 				childrenKeys = append(childrenKeys, k)
 			}
@@ -131,13 +153,13 @@ func encodeNode(w io.Writer, tag string, value any, cfg *config, depth int) erro
 
 		for _, k := range allKeys {
 			if strings.HasPrefix(k, "@") {
-				val := v[k]
-				esc := escapeString(fmt.Sprintf("%v", val))
-				startElem += fmt.Sprintf(` %s="%s"`, strings.TrimPrefix(k, "@"), esc)
+				attrs += formatAttr(strings.TrimPrefix(k, "@"), v[k], cfg)
 			} else if k == "#text" {
 				content = v[k]
 			} else if k == "#cdata" {
-				cdataContent = fmt.Sprintf("%v", v[k])
+				cdataContent = formatValue(v[k], cfg)
+			} else if k == "#seq" {
+				seq, _ = v[k].([]any)
 			} else {
 				childrenKeys = append(childrenKeys, k)
 			}
@@ -148,14 +170,44 @@ func encodeNode(w io.Writer, tag string, value any, cfg *config, depth int) erro
 		content = v
 	}
 
-	startElem += ">"
+	if cfg.nsAfterAttrs {
+		startElem += attrs + nsDecls + ">"
+	} else {
+		startElem += nsDecls + attrs + ">"
+	}
 	fmt.Fprint(w, indent+startElem)
+	if cfg.htmlOutput && containsFold(htmlVoidElements, tag) {
+		return nil
+	}
+	// HTML script and style text is not parsed for entities
+	rawText := cfg.htmlOutput && (strings.EqualFold(tag, "script") || strings.EqualFold(tag, "style"))
+
+	// Mixed content (text alongside child elements) is written inline:
+	// indentation would become part of the text.
+	if cfg.prettyPrint && isMixedContent(content, cdataContent, childrenKeys, seq) {
+		inline := *cfg
+		inline.prettyPrint = false
+		cfg = &inline
+	}
+
+	// Document-order content (PreserveCommentOrder) replaces the key order.
+	if seq != nil {
+		if err := encodeSeq(w, seq, cfg, depth, rawText); err != nil {
+			return err
+		}
+		if cfg.prettyPrint {
+			fmt.Fprint(w, "\n"+strings.Repeat("  ", depth))
+		}
+		fmt.Fprint(w, "</"+tag+">")
+		return nil
+	}
 
 	// Write Content
+	if content != nil {
+		writeText(w, formatValue(content, cfg), rawText)
+	}
 	if cdataContent != "" {
-		fmt.Fprint(w, "<![CDATA["+cdataContent+"]]>")
-	} else if content != nil {
-		xml.EscapeText(w, []byte(fmt.Sprintf("%v", content)))
+		writeCDATA(w, cdataContent)
 	}
 
 	// Write Children
@@ -192,6 +244,195 @@ func encodeNode(w io.Writer, tag string, value any, cfg *config, depth int) erro
 	return nil
 }
 
+// isMixedContent reports whether a node holds text and child elements
+// together, either as "#text"/"#cdata" plus children or inside "#seq".
+func isMixedContent(content any, cdata string, childrenKeys []string, seq []any) bool {
+	if seq != nil {
+		hasText, hasElem := false, false
+		for _, item := range seq {
+			entry, ok := item.(*OrderedMap)
+			if !ok {
+				continue
+			}
+			for _, k := range entry.Keys() {
+				switch {
+				case k == "#text":
+					hasText = true
+				case isPlainKey(k):
+					hasElem = true
+				}
+			}
+		}
+		return hasText && hasElem
+	}
+	return (content != nil || cdata != "") && len(childrenKeys) > 0
+}
+
+// encodeSeq writes the "#seq" entries of a node in order: comments,
+// processing instructions, text fragments and child elements. rawText
+// writes the text unescaped (HTML script and style).
+func encodeSeq(w io.Writer, seq []any, cfg *config, depth int, rawText bool) error {
+	for _, item := range seq {
+		entry, ok := item.(*OrderedMap)
+		if !ok {
+			continue
+		}
+		for _, k := range entry.Keys() {
+			val := entry.Get(k)
+			switch k {
+			case "#comment":
+				if cfg.prettyPrint {
+					fmt.Fprint(w, "\n"+strings.Repeat("  ", depth+1))
+				}
+				fmt.Fprintf(w, "<!--%v-->", val)
+			case "#pi":
+				if cfg.prettyPrint {
+					fmt.Fprint(w, "\n"+strings.Repeat("  ", depth+1))
+				}
+				fmt.Fprintf(w, "<?%v?>", val)
+			case "#text":
+				writeText(w, formatValue(val, cfg), rawText)
+			case "#cdata":
+				writeCDATA(w, formatValue(val, cfg))
+			default:
+				if err := encodeNode(w, k, val, cfg, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeText writes element text, escaped unless raw.
+func writeText(w io.Writer, s string, raw bool) {
+	if raw {
+		io.WriteString(w, s)
+		return
+	}
+	xml.EscapeText(w, []byte(s))
+}
+
+// formatAttr renders an attribute as ` name="value"`, or "" when it is
+// omitted (WithOmitEmptyAttributes, false HTML boolean attributes).
+func formatAttr(name string, v any, cfg *config) string {
+	val := formatValue(v, cfg)
+	if cfg.htmlOutput && containsFold(htmlBooleanAttrs, name) {
+		if b, ok := v.(bool); (ok && !b) || val == "false" {
+			return ""
+		}
+		return " " + name
+	}
+	if val == "" && cfg.omitEmptyAttrs {
+		return ""
+	}
+	return fmt.Sprintf(` %s="%s"`, name, escapeAttrValue(val))
+}
+
+// htmlBooleanAttrs are the HTML attributes whose presence alone means true
+// (WithHTMLOutput).
+var htmlBooleanAttrs = []string{
+	"allowfullscreen", "async", "autofocus", "autoplay", "checked",
+	"controls", "default", "defer", "disabled", "formnovalidate", "hidden",
+	"inert", "ismap", "itemscope", "loop", "multiple", "muted", "nomodule",
+	"novalidate", "open", "playsinline", "readonly", "required",
+	"reversed", "selected",
+}
+
+// writeCDATA writes s as a CDATA section, splitting it where it contains
+// the "]]>" terminator.
+func writeCDATA(w io.Writer, s string) {
+	fmt.Fprint(w, "<![CDATA["+strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>")+"]]>")
+}
+
+// writeDoctype writes the prolog directive of a document before its root
+// element: a raw root "#directive" ("DOCTYPE html", as the parser stores it
+// with PreserveCommentOrder) or a structured "#doctype" map with "name" and
+// optional "public" and "system" identifiers.
+func writeDoctype(w io.Writer, directive, doctype any) error {
+	if directive != nil {
+		_, err := fmt.Fprintf(w, "<!%v>", directive)
+		return err
+	}
+	var get func(string) string
+	switch d := doctype.(type) {
+	case nil:
+		return nil
+	case string:
+		_, err := fmt.Fprintf(w, "<!DOCTYPE %s>", d)
+		return err
+	case *OrderedMap:
+		get = d.String
+	case map[string]any:
+		get = func(k string) string {
+			if v, ok := d[k]; ok {
+				return fmt.Sprint(v)
+			}
+			return ""
+		}
+	default:
+		return fmt.Errorf("unsupported #doctype value %T", doctype)
+	}
+
+	name := get("name")
+	if name == "" {
+		return errors.New("#doctype requires a name")
+	}
+	decl := "<!DOCTYPE " + name
+	switch public, system := get("public"), get("system"); {
+	case public != "":
+		decl += fmt.Sprintf(` PUBLIC "%s"`, public)
+		if system != "" {
+			decl += fmt.Sprintf(` "%s"`, system)
+		}
+	case system != "":
+		decl += fmt.Sprintf(` SYSTEM "%s"`, system)
+	}
+	_, err := io.WriteString(w, decl+">")
+	return err
+}
+
+// formatValue renders a scalar value as XML text, with type-aware formats
+// for values whose %v form is not what a schema expects.
+func formatValue(v any, cfg *config) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case time.Time:
+		layout := cfg.timeLayout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return t.Format(layout)
+	case json.Number:
+		return t.String()
+	case bool:
+		if cfg.boolText != nil {
+			if t {
+				return cfg.boolText[0]
+			}
+			return cfg.boolText[1]
+		}
+		return strconv.FormatBool(t)
+	case []byte:
+		if cfg.bytesEncoder != nil {
+			return cfg.bytesEncoder(t)
+		}
+		return base64.StdEncoding.EncodeToString(t)
+	case []any:
+		// Token lists (e.g. SplitAttributes) are written space-separated,
+		// like xs:list values.
+		parts := make([]string, len(t))
+		for i, item := range t {
+			parts[i] = formatValue(item, cfg)
+		}
+		return strings.Join(parts, " ")
+	case fmt.Stringer:
+		return t.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
 // Helpers
 func sortedKeys(m map[string]any) []string {
 	keys := make([]string, 0, len(m))
@@ -202,8 +443,21 @@ func sortedKeys(m map[string]any) []string {
 	return keys
 }
 
-func escapeString(s string) string {
-	var b bytes.Buffer
-	xml.EscapeText(&b, []byte(s))
-	return b.String()
+// attrValueEscaper escapes attribute values per the XML attribute-value
+// normalization rules: besides the markup characters, literal tabs, newlines
+// and carriage returns are written as character references so a conforming
+// parser reads them back unchanged instead of normalizing them to spaces.
+var attrValueEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+	"\t", "&#9;",
+	"\n", "&#10;",
+	"\r", "&#13;",
+)
+
+func escapeAttrValue(s string) string {
+	return attrValueEscaper.Replace(s)
 }
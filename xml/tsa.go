@@ -0,0 +1,199 @@
+package xml
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// ============================================================================
+// RFC 3161 TIMESTAMP CLIENT (TSA)
+//
+// Scope: builds the TimeStampReq (SHA-256 imprint, random nonce, certReq),
+// posts it and parses the TimeStampResp far enough to check the status, the
+// imprint and the nonce, and to return the token and its genTime. The CMS
+// signature inside the token is NOT verified here: embed the token (e.g.
+// as an XAdES-T EncapsulatedTimeStamp) and let the verifier check it.
+// ============================================================================
+
+var (
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	tsaStatusGranted = map[int]bool{0: true, 1: true} // granted, grantedWithMods
+)
+
+// --- Raw ASN.1 structs (unexported) -----------------------------------------
+
+type tsaAlgorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type tsaMessageImprint struct {
+	HashAlgorithm tsaAlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type tsaRequest struct {
+	Version        int
+	MessageImprint tsaMessageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+type tsaStatusInfo struct {
+	Status       int
+	StatusString []asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+type tsaResponse struct {
+	Status tsaStatusInfo
+	Token  asn1.RawValue `asn1:"optional"`
+}
+
+type tsaContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT SignedData
+}
+
+type tsaEncapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional"` // [0] EXPLICIT OCTET STRING
+}
+
+type tsaSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo tsaEncapContentInfo
+}
+
+type tsaAccuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tsaTSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint tsaMessageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time   `asn1:"generalized"`
+	Accuracy       tsaAccuracy `asn1:"optional"`
+	Ordering       bool        `asn1:"optional"`
+	Nonce          *big.Int    `asn1:"optional"`
+}
+
+// TSAClient requests RFC 3161 timestamps from a Time Stamping Authority.
+type TSAClient struct {
+	URL        string
+	HTTPClient *http.Client // nil = a client with a 30-second timeout
+}
+
+// httpClient returns c.HTTPClient, or, like FetchWSDL, a client with a
+// 30-second timeout: http.DefaultClient never times out, so an unresponsive
+// TSA would hang the signing call.
+func (c *TSAClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// Timestamp asks the TSA to timestamp data (hashed with SHA-256) and returns
+// the DER-encoded TimeStampToken and the time the TSA asserted. It fails if
+// the TSA rejects the request or answers for a different imprint or nonce.
+func (c *TSAClient) Timestamp(data []byte) (token []byte, genTime time.Time, err error) {
+	digest := sha256.Sum256(data)
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: generating nonce: %w", err)
+	}
+
+	reqBytes, err := asn1.Marshal(tsaRequest{
+		Version: 1,
+		MessageImprint: tsaMessageImprint{
+			HashAlgorithm: tsaAlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest[:],
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: encoding request: %w", err)
+	}
+
+	resp, err := c.httpClient().Post(c.URL, "application/timestamp-query", bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, time.Time{}, fmt.Errorf("tsa: HTTP %d", resp.StatusCode)
+	}
+
+	return parseTSAResponse(respBytes, digest[:], nonce)
+}
+
+// parseTSAResponse decodes a TimeStampResp and checks it answers the request
+// identified by digest and nonce.
+func parseTSAResponse(respBytes, digest []byte, nonce *big.Int) ([]byte, time.Time, error) {
+	var tsResp tsaResponse
+	if _, err := asn1.Unmarshal(respBytes, &tsResp); err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: invalid response: %w", err)
+	}
+	if !tsaStatusGranted[tsResp.Status.Status] {
+		msg := ""
+		for _, s := range tsResp.Status.StatusString {
+			msg += " " + string(s.Bytes)
+		}
+		return nil, time.Time{}, fmt.Errorf("tsa: request rejected (status %d)%s", tsResp.Status.Status, msg)
+	}
+	if len(tsResp.Token.FullBytes) == 0 {
+		return nil, time.Time{}, fmt.Errorf("tsa: response carries no timestamp token")
+	}
+
+	var ci tsaContentInfo
+	if _, err := asn1.Unmarshal(tsResp.Token.FullBytes, &ci); err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: invalid token: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, time.Time{}, fmt.Errorf("tsa: token is not SignedData (%v)", ci.ContentType)
+	}
+	var sd tsaSignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: invalid SignedData: %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, time.Time{}, fmt.Errorf("tsa: token does not carry a TSTInfo (%v)", sd.EncapContentInfo.EContentType)
+	}
+	var tstDER []byte
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &tstDER); err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: invalid TSTInfo content: %w", err)
+	}
+	var info tsaTSTInfo
+	if _, err := asn1.Unmarshal(tstDER, &info); err != nil {
+		return nil, time.Time{}, fmt.Errorf("tsa: invalid TSTInfo: %w", err)
+	}
+
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, time.Time{}, fmt.Errorf("tsa: token imprint does not match the timestamped data")
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, time.Time{}, fmt.Errorf("tsa: token nonce does not match the request")
+	}
+	return tsResp.Token.FullBytes, info.GenTime, nil
+}
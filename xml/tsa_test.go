@@ -0,0 +1,197 @@
+package xml
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockTSAResponse builds a minimal granted TimeStampResp for req, the way a
+// TSA would (minus the CMS signer infos, which the client does not parse).
+func mockTSAResponse(t *testing.T, req tsaRequest, genTime time.Time, nonce *big.Int) []byte {
+	t.Helper()
+	tstDER, err := asn1.Marshal(tsaTSTInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   big.NewInt(42),
+		GenTime:        genTime,
+		Nonce:          nonce,
+	})
+	if err != nil {
+		t.Fatalf("marshal TSTInfo: %v", err)
+	}
+	eContent, _ := asn1.Marshal(tstDER) // OCTET STRING
+	sdDER, err := asn1.Marshal(tsaSignedData{
+		Version:          3,
+		DigestAlgorithms: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+		EncapContentInfo: tsaEncapContentInfo{
+			EContentType: oidTSTInfo,
+			EContent:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: eContent},
+		},
+	})
+	if err != nil {
+		t.Fatalf("marshal SignedData: %v", err)
+	}
+	tokenDER, _ := asn1.Marshal(tsaContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdDER},
+	})
+	respDER, err := asn1.Marshal(tsaResponse{
+		Status: tsaStatusInfo{Status: 0},
+		Token:  asn1.RawValue{FullBytes: tokenDER},
+	})
+	if err != nil {
+		t.Fatalf("marshal TimeStampResp: %v", err)
+	}
+	return respDER
+}
+
+func TestTSAClient_Timestamp(t *testing.T) {
+	data := []byte("<Invoice>signed content</Invoice>")
+	genTime := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/timestamp-query" {
+			t.Errorf("Content-Type = %q, want application/timestamp-query", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req tsaRequest
+		if _, err := asn1.Unmarshal(body, &req); err != nil {
+			t.Fatalf("TSA received a malformed request: %v", err)
+		}
+		want := sha256.Sum256(data)
+		if req.Version != 1 || !req.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(req.MessageImprint.HashedMessage, want[:]) {
+			t.Errorf("unexpected request: version %d, alg %v, imprint %x", req.Version, req.MessageImprint.HashAlgorithm.Algorithm, req.MessageImprint.HashedMessage)
+		}
+		if req.Nonce == nil || !req.CertReq {
+			t.Errorf("request should carry a nonce and certReq, got nonce=%v certReq=%v", req.Nonce, req.CertReq)
+		}
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(mockTSAResponse(t, req, genTime, req.Nonce))
+	}))
+	defer ts.Close()
+
+	client := &TSAClient{URL: ts.URL}
+	token, got, err := client.Timestamp(data)
+	if err != nil {
+		t.Fatalf("Timestamp failed: %v", err)
+	}
+	if !got.Equal(genTime) {
+		t.Errorf("genTime = %v, want %v", got, genTime)
+	}
+	var ci tsaContentInfo
+	if _, err := asn1.Unmarshal(token, &ci); err != nil || !ci.ContentType.Equal(oidSignedData) {
+		t.Errorf("token is not a SignedData ContentInfo: %v", err)
+	}
+}
+
+func TestTSAClient_RejectsMismatchedNonce(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req tsaRequest
+		asn1.Unmarshal(body, &req)
+		w.Write(mockTSAResponse(t, req, time.Now().UTC(), big.NewInt(7)))
+	}))
+	defer ts.Close()
+
+	_, _, err := (&TSAClient{URL: ts.URL}).Timestamp([]byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("Timestamp with a replayed nonce = %v, want nonce error", err)
+	}
+}
+
+func TestTSAClient_RejectedStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text, _ := asn1.MarshalWithParams("bad alg", "utf8")
+		resp, _ := asn1.Marshal(tsaResponse{Status: tsaStatusInfo{
+			Status:       2,
+			StatusString: []asn1.RawValue{{FullBytes: text}},
+		}})
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	_, _, err := (&TSAClient{URL: ts.URL}).Timestamp([]byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "status 2") || !strings.Contains(err.Error(), "bad alg") {
+		t.Errorf("Timestamp on rejection = %v, want status 2 with text", err)
+	}
+}
+
+func TestTSAClient_DefaultTimeout(t *testing.T) {
+	if got := (&TSAClient{}).httpClient(); got == http.DefaultClient || got.Timeout == 0 {
+		t.Errorf("default TSA client has no timeout: %+v", got)
+	}
+	custom := &http.Client{}
+	if got := (&TSAClient{HTTPClient: custom}).httpClient(); got != custom {
+		t.Error("HTTPClient was not used")
+	}
+}
+
+func TestAddTimestamp(t *testing.T) {
+	certPEM, keyPEM := generateTestKeys(t)
+	s, _ := NewSigner(certPEM, keyPEM)
+	doc, inner := buildSignableDoc(t)
+	preSignBytes, _ := Marshal(doc)
+	sig, err := s.CreateXadesSignature([]byte(preSignBytes))
+	if err != nil {
+		t.Fatalf("CreateXadesSignature error: %v", err)
+	}
+
+	wantData := `<ds:SignatureValue xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` + sig.String("ds:SignatureValue") + `</ds:SignatureValue>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req tsaRequest
+		asn1.Unmarshal(body, &req)
+		if want := sha256.Sum256([]byte(wantData)); !bytes.Equal(req.MessageImprint.HashedMessage, want[:]) {
+			t.Errorf("imprint is not the digest of the canonical ds:SignatureValue")
+		}
+		w.Write(mockTSAResponse(t, req, time.Now().UTC(), req.Nonce))
+	}))
+	defer ts.Close()
+
+	if err := AddTimestamp(sig, ts.URL); err != nil {
+		t.Fatalf("AddTimestamp failed: %v", err)
+	}
+	qp := sig.GetNode("ds:Object/xades:QualifyingProperties")
+	if keys := qp.Keys(); keys[len(keys)-1] != "xades:UnsignedProperties" {
+		t.Errorf("QualifyingProperties keys = %v, want UnsignedProperties last", keys)
+	}
+	stamp := qp.GetNode("xades:UnsignedProperties/xades:UnsignedSignatureProperties/xades:SignatureTimeStamp")
+	if stamp == nil {
+		t.Fatal("xades:SignatureTimeStamp not added")
+	}
+	if got := stamp.String("ds:CanonicalizationMethod/@Algorithm"); got != ExclusiveC14NAlgorithm {
+		t.Errorf("CanonicalizationMethod = %s", got)
+	}
+	token, err := base64.StdEncoding.DecodeString(stamp.String("xades:EncapsulatedTimeStamp"))
+	var ci tsaContentInfo
+	if err != nil || len(token) == 0 {
+		t.Errorf("EncapsulatedTimeStamp is not base64: %v", err)
+	} else if _, err := asn1.Unmarshal(token, &ci); err != nil || !ci.ContentType.Equal(oidSignedData) {
+		t.Errorf("EncapsulatedTimeStamp is not a timestamp token: %v", err)
+	}
+
+	// The timestamp is unsigned: the signature still verifies
+	inner.Set("ds:Signature", sig)
+	finalXML, _ := Marshal(doc)
+	if err := s.Verify([]byte(finalXML)); err != nil {
+		t.Errorf("Verify after AddTimestamp failed: %v", err)
+	}
+
+	if err := AddTimestamp(sig, ts.URL); err == nil {
+		t.Error("expected an error timestamping twice")
+	}
+	bes, _ := s.CreateSignature([]byte(preSignBytes))
+	if err := AddTimestamp(bes, ts.URL); err == nil || !strings.Contains(err.Error(), "XAdES") {
+		t.Errorf("AddTimestamp on a plain XML-DSig signature = %v, want a XAdES error", err)
+	}
+}
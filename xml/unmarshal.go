@@ -0,0 +1,225 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// STRUCT DECODING (Unmarshal)
+//
+// Parses with MapXML (so every parser Option applies: legacy charsets, soup
+// mode, namespace aliases...) and then fills the struct from the resulting
+// OrderedMap, following the usual `xml` tags:
+//
+//	`xml:"name"`       child element (or "a>b" for a nested one)
+//	`xml:"id,attr"`    attribute
+//	`xml:",chardata"`  the element's own text
+//	`xml:"-"`          ignored
+//
+// Untagged exported fields match the element of the same name. Missing
+// elements leave the field untouched.
+// ============================================================================
+
+// UnmarshalTypeError reports a value that could not be converted to the
+// type of the field it maps to.
+type UnmarshalTypeError struct {
+	Path  string // Field path, e.g. "Order.Items[2].Qty"
+	Value string
+	Type  reflect.Type
+	Err   error // Underlying conversion error, if any
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("xml: cannot unmarshal %q into %s (%s)", e.Value, e.Path, e.Type)
+}
+
+func (e *UnmarshalTypeError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	xmlNameType = reflect.TypeOf(xml.Name{})
+	orderedType = reflect.TypeOf(&OrderedMap{})
+)
+
+// Unmarshal parses data and stores the root element into v, which must be
+// a non-nil pointer to a struct.
+//
+//	var inv Invoice
+//	err := xml.Unmarshal(body, &inv, xml.EnableLegacyCharsets())
+func Unmarshal(data []byte, v any, opts ...Option) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("xml: Unmarshal needs a non-nil pointer, got %T", v)
+	}
+
+	m, err := MapXML(bytes.NewReader(data), opts...)
+	if err != nil {
+		return err
+	}
+	for _, k := range m.Keys() {
+		if strings.HasPrefix(k, "#") || strings.HasPrefix(k, "@") {
+			continue
+		}
+		elem := rv.Elem()
+		if elem.Kind() == reflect.Struct {
+			if f, ok := elem.Type().FieldByName("XMLName"); ok && f.Type == xmlNameType {
+				elem.FieldByIndex(f.Index).Set(reflect.ValueOf(xml.Name{Local: k}))
+			}
+		}
+		return unmarshalValue(m.Get(k), elem, elem.Type().Name())
+	}
+	return fmt.Errorf("xml: Unmarshal found no root element")
+}
+
+// unmarshalValue stores val (a node of the parsed tree) into rv.
+func unmarshalValue(val any, rv reflect.Value, path string) error {
+	if val == nil {
+		return nil
+	}
+	t := rv.Type()
+
+	switch {
+	case t == orderedType:
+		if om, ok := val.(*OrderedMap); ok {
+			rv.Set(reflect.ValueOf(om))
+		}
+		return nil
+	case t.Kind() == reflect.Interface && t.NumMethod() == 0:
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	case t.Kind() == reflect.Pointer:
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		return unmarshalValue(val, rv.Elem(), path)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		items := AsSlice(val)
+		list := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := unmarshalValue(item, list.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(list)
+		return nil
+	case t.Kind() == reflect.Struct && t != timeType:
+		return unmarshalStruct(val, rv, path)
+	}
+
+	// Scalar target: an element with attributes still carries its #text
+	if om, ok := val.(*OrderedMap); ok {
+		val = om.Get("#text")
+		if val == nil {
+			return nil
+		}
+	}
+	return setScalar(val, rv, path)
+}
+
+// unmarshalStruct fills the fields of rv from node.
+func unmarshalStruct(val any, rv reflect.Value, path string) error {
+	node, _ := val.(*OrderedMap)
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Type == xmlNameType {
+			continue
+		}
+		tag := f.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		var fieldVal any
+		switch {
+		case hasFlag(flags, "chardata"):
+			fieldVal = val
+			if node != nil {
+				fieldVal = node.Get("#text")
+			}
+		case node == nil:
+			continue
+		case hasFlag(flags, "attr"):
+			fieldVal = node.Get("@" + name)
+		default:
+			fieldVal = node.GetPath(strings.ReplaceAll(name, ">", "/"))
+		}
+		if err := unmarshalValue(fieldVal, rv.Field(i), path+"."+f.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setScalar converts a leaf value to the kind of rv.
+func setScalar(val any, rv reflect.Value, path string) error {
+	s := strings.TrimSpace(AsString(val))
+	fail := func(err error) error {
+		return &UnmarshalTypeError{Path: path, Value: s, Type: rv.Type(), Err: err}
+	}
+
+	if rv.Type() == timeType {
+		tm, err := AsTime(s)
+		if err != nil {
+			return fail(err)
+		}
+		rv.Set(reflect.ValueOf(tm))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(AsString(val))
+	case reflect.Slice: // []byte
+		rv.SetBytes([]byte(AsString(val)))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fail(err)
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, rv.Type().Bits())
+		if err != nil {
+			return fail(err)
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, rv.Type().Bits())
+		if err != nil {
+			return fail(err)
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, rv.Type().Bits())
+		if err != nil {
+			return fail(err)
+		}
+		rv.SetFloat(n)
+	default:
+		return fail(nil)
+	}
+	return nil
+}
+
+// hasFlag reports whether the comma-separated tag options contain flag.
+func hasFlag(flags, flag string) bool {
+	for _, f := range strings.Split(flags, ",") {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package xml
+
+import (
+	"encoding/xml"
+	"errors"
+	"testing"
+	"time"
+)
+
+type umLine struct {
+	SKU   string  `xml:"sku,attr"`
+	Qty   int     `xml:"qty"`
+	Price float64 `xml:"price"`
+}
+
+type umNote struct {
+	Lang string `xml:"lang,attr"`
+	Text string `xml:",chardata"`
+}
+
+type umOrder struct {
+	XMLName  xml.Name
+	ID       int       `xml:"id,attr"`
+	Customer string    `xml:"customer>name"`
+	Date     time.Time `xml:"date"`
+	Paid     bool      `xml:"paid"`
+	Lines    []umLine  `xml:"line"`
+	Tags     []string  `xml:"tag"`
+	Note     *umNote   `xml:"note"`
+	Internal string    `xml:"-"`
+	Status   string
+}
+
+func TestUnmarshal(t *testing.T) {
+	data := []byte(`<order id="42">
+		<customer><name>Ana</name></customer>
+		<date>2025-12-19</date>
+		<paid>true</paid>
+		<line sku="A1"><qty>2</qty><price>9.5</price></line>
+		<tag>rush</tag>
+		<note lang="es">Frágil</note>
+		<Internal>secret</Internal>
+		<Status>open</Status>
+	</order>`)
+
+	var o umOrder
+	if err := Unmarshal(data, &o, ForceArray("line")); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if o.XMLName.Local != "order" || o.ID != 42 || o.Customer != "Ana" || !o.Paid || o.Status != "open" {
+		t.Errorf("unexpected scalars: %+v", o)
+	}
+	if !o.Date.Equal(time.Date(2025, 12, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Date = %v", o.Date)
+	}
+	if len(o.Lines) != 1 || o.Lines[0] != (umLine{SKU: "A1", Qty: 2, Price: 9.5}) {
+		t.Errorf("Lines = %+v", o.Lines)
+	}
+	if len(o.Tags) != 1 || o.Tags[0] != "rush" {
+		t.Errorf("Tags = %v", o.Tags)
+	}
+	if o.Note == nil || o.Note.Lang != "es" || o.Note.Text != "Frágil" {
+		t.Errorf("Note = %+v", o.Note)
+	}
+	if o.Internal != "" {
+		t.Errorf("xml:\"-\" field was filled: %q", o.Internal)
+	}
+}
+
+func TestUnmarshal_LegacyCharset(t *testing.T) {
+	data := append([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?><order><Status>`), 0xC9, 'x', 'i', 't', 'o')
+	data = append(data, []byte(`</Status></order>`)...)
+
+	var o umOrder
+	if err := Unmarshal(data, &o, EnableLegacyCharsets()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if o.Status != "Éxito" {
+		t.Errorf("Status = %q, want Éxito", o.Status)
+	}
+}
+
+func TestUnmarshal_TypeError(t *testing.T) {
+	var o umOrder
+	err := Unmarshal([]byte(`<order><line sku="A1"><qty>two</qty></line></order>`), &o)
+
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected *UnmarshalTypeError, got %T: %v", err, err)
+	}
+	if typeErr.Path != "umOrder.Lines[0].Qty" || typeErr.Value != "two" {
+		t.Errorf("error = %v", err)
+	}
+
+	if err := Unmarshal([]byte(`<order/>`), o); err == nil {
+		t.Error("Unmarshal into a non-pointer should fail")
+	}
+}
@@ -1,10 +1,13 @@
 package xml
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -39,28 +42,59 @@ var windows1252Table = [256]rune{
 }
 
 // latin1Reader implements io.Reader to decode ISO-8859-1 on the fly.
+//
+// Each call reads up to len(p) input bytes, so ASCII-heavy content (one
+// byte in, one byte out) flows at full buffer size. Multi-byte runes that
+// do not fit in p are kept in pending and delivered on the next call.
 type latin1Reader struct {
-	r io.Reader
+	r       io.Reader
+	buf     []byte // Raw input scratch, reused across calls
+	pending []byte // Encoded UTF-8 not yet delivered
+	err     error  // Input error deferred until pending is drained
 }
 
 func (l *latin1Reader) Read(p []byte) (n int, err error) {
-	maxRead := len(p) / 4
-	if maxRead == 0 && len(p) > 0 {
-		maxRead = 1
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(l.pending) > 0 {
+		n = copy(p, l.pending)
+		l.pending = l.pending[n:]
+		if len(l.pending) == 0 {
+			return n, l.err
+		}
+		return n, nil
+	}
+	if l.err != nil {
+		return 0, l.err
 	}
-	buf := make([]byte, maxRead)
-	nRead, errRead := l.r.Read(buf)
 
-	bytesWritten := 0
-	for i := 0; i < nRead; i++ {
-		r := windows1252Table[buf[i]]
-		if bytesWritten+utf8.RuneLen(r) > len(p) {
-			break
+	if cap(l.buf) < len(p) {
+		l.buf = make([]byte, len(p))
+	}
+	nRead, errRead := l.r.Read(l.buf[:len(p)])
+
+	var rb [utf8.UTFMax]byte
+	for _, b := range l.buf[:nRead] {
+		if b < utf8.RuneSelf && n < len(p) {
+			p[n] = b
+			n++
+			continue
+		}
+		w := utf8.EncodeRune(rb[:], windows1252Table[b])
+		c := 0
+		if n < len(p) {
+			c = copy(p[n:], rb[:w])
+			n += c
 		}
-		w := utf8.EncodeRune(p[bytesWritten:], r)
-		bytesWritten += w
+		l.pending = append(l.pending, rb[c:w]...)
 	}
-	return bytesWritten, errRead
+
+	if len(l.pending) > 0 {
+		l.err = errRead
+		return n, nil
+	}
+	return n, errRead
 }
 
 // charsetReader injects ISO-8859-1 support into the XML decoder.
@@ -113,6 +147,40 @@ func sanitizeSoup(r io.Reader) io.Reader {
 	return bytes.NewReader(data)
 }
 
+// xmlFile is an opened input file: reads go through a transparent gzip
+// decompressor when the content is gzip-compressed (by magic number, not
+// by extension). Close releases both.
+type xmlFile struct {
+	io.Reader
+	f  *os.File
+	gz *gzip.Reader
+}
+
+func openXMLFile(path string) (*xmlFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	file := &xmlFile{Reader: br, f: f}
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		file.Reader, file.gz = gz, gz
+	}
+	return file, nil
+}
+
+func (x *xmlFile) Close() error {
+	if x.gz != nil {
+		x.gz.Close()
+	}
+	return x.f.Close()
+}
+
 // ============================================================================
 // 2. TYPE COERCION (SAFE GETTERS)
 // ============================================================================
@@ -173,8 +241,18 @@ func AsFloat(v any) float64 {
 }
 
 // AsBool forces conversion to bool.
-func AsBool(v any) bool {
+// Pass WithBoolLiterals to use the same truthy set as the parser.
+func AsBool(v any, opts ...Option) bool {
 	s := strings.ToLower(fmt.Sprintf("%v", v))
+	if len(opts) > 0 {
+		cfg := defaultConfig()
+		for _, opt := range opts {
+			opt(cfg)
+		}
+		if cfg.boolTruthy != nil {
+			return containsFold(cfg.boolTruthy, s)
+		}
+	}
 	return s == "true" || s == "1" || s == "yes" || s == "on" || s == "ok"
 }
 
@@ -242,7 +320,7 @@ func textRecursive(data any, sb *strings.Builder) {
 			sb.WriteString(fmt.Sprintf("%v", t))
 		}
 		v.ForEach(func(k string, val any) bool {
-			if !strings.HasPrefix(k, "@") && k != "#text" && k != "#seq" {
+			if !strings.HasPrefix(k, "@") && k != "#text" && k != "#seq" && k != "#comment" && k != "#pi" {
 				textRecursive(val, sb)
 			}
 			return true
@@ -258,7 +336,7 @@ func textRecursive(data any, sb *strings.Builder) {
 			sb.WriteString(fmt.Sprintf("%v", t))
 		}
 		for k, val := range v {
-			if !strings.HasPrefix(k, "@") && k != "#text" && k != "#seq" {
+			if !strings.HasPrefix(k, "@") && k != "#text" && k != "#seq" && k != "#comment" && k != "#pi" {
 				textRecursive(val, sb)
 			}
 		}
@@ -268,3 +346,70 @@ func textRecursive(data any, sb *strings.Builder) {
 		}
 	}
 }
+
+// DocStats summarizes the shape of a parsed document (see Stats).
+type DocStats struct {
+	Elements   int            // Total element occurrences
+	Attributes int            // Total attributes, xmlns declarations included
+	MaxDepth   int            // Deepest element nesting (root = 1)
+	Tags       map[string]int // Occurrences per tag name, as stored (prefix included)
+	Namespaces map[string]int // Occurrences per namespace prefix ("" = unprefixed)
+}
+
+// Stats walks a parsed document (as returned by MapXML) and reports element
+// counts per tag and per namespace prefix, total nodes and maximum depth.
+// Namespaces are counted by the prefix in the stored key, so elements whose
+// namespace was not aliased with RegisterNamespace count as unprefixed.
+func Stats(data any) DocStats {
+	st := DocStats{Tags: make(map[string]int), Namespaces: make(map[string]int)}
+	statsRecursive(data, 0, &st)
+	return st
+}
+
+// ElementNames returns each distinct element name in a parsed document and
+// how many times it occurs at any depth, for discovering the implicit
+// schema of an unknown document. It is Stats(data).Tags.
+func ElementNames(data any) map[string]int {
+	return Stats(data).Tags
+}
+
+func statsRecursive(data any, depth int, st *DocStats) {
+	visit := func(k string, val any) {
+		switch {
+		case strings.HasPrefix(k, "@"):
+			st.Attributes++
+		case strings.HasPrefix(k, "#"):
+			// #text, #seq (duplicates the children), #comment...
+		default:
+			items, ok := val.([]any)
+			if !ok {
+				items = []any{val}
+			}
+			prefix := ""
+			if i := strings.Index(k, ":"); i >= 0 {
+				prefix = k[:i]
+			}
+			for _, item := range items {
+				st.Elements++
+				st.Tags[k]++
+				st.Namespaces[prefix]++
+				if depth+1 > st.MaxDepth {
+					st.MaxDepth = depth + 1
+				}
+				statsRecursive(item, depth+1, st)
+			}
+		}
+	}
+
+	switch v := data.(type) {
+	case *OrderedMap:
+		v.ForEach(func(k string, val any) bool {
+			visit(k, val)
+			return true
+		})
+	case map[string]any:
+		for k, val := range v {
+			visit(k, val)
+		}
+	}
+}
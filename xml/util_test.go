@@ -1,8 +1,10 @@
 package xml
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -108,6 +110,39 @@ func TestAsBool(t *testing.T) {
 	}
 }
 
+func TestBoolLiterals(t *testing.T) {
+	literals := WithBoolLiterals([]string{"yes", "si"}, []string{"no"})
+	doc := `<user><active>Yes</active><admin>no</admin><vip>maybe</vip></user>`
+
+	m, err := MapXML(strings.NewReader(doc), EnableExperimental(), literals)
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+	if got := m.GetPath("user/active"); got != true {
+		t.Errorf("active = %#v, want true", got)
+	}
+	if got := m.GetPath("user/admin"); got != false {
+		t.Errorf("admin = %#v, want false", got)
+	}
+	if got := m.GetPath("user/vip"); got != "maybe" {
+		t.Errorf("vip = %#v, want \"maybe\"", got)
+	}
+
+	// Default inference keeps "yes" as a string
+	m, _ = MapXML(strings.NewReader(doc), EnableExperimental())
+	if got := m.GetPath("user/active"); got != "Yes" {
+		t.Errorf("default active = %#v, want \"Yes\"", got)
+	}
+
+	// AsBool consults the same literal set
+	if !AsBool("si", literals) {
+		t.Error("AsBool(si) with custom literals = false, want true")
+	}
+	if AsBool("on", literals) {
+		t.Error("AsBool(on) with custom literals = true, want false")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // AsSlice
 // ---------------------------------------------------------------------------
@@ -263,3 +298,98 @@ func TestLatin1Reader_DecodesToUTF8(t *testing.T) {
 		t.Errorf("latin1Reader decoded %q, want é", got)
 	}
 }
+
+func TestLatin1Reader_SmallBuffers(t *testing.T) {
+	// Multi-byte runes (é = 2 bytes, € from 0x80 = 3 bytes) must survive
+	// being split across reads smaller than their UTF-8 encoding.
+	src := []byte("a\xe9b\x80c\xe9\xe9")
+	want := "aéb€céé"
+	for _, size := range []int{1, 2, 3, 5, 64} {
+		r := &latin1Reader{r: bytes.NewReader(src)}
+		var out []byte
+		buf := make([]byte, size)
+		for {
+			n, err := r.Read(buf)
+			out = append(out, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("size %d: Read error: %v", size, err)
+			}
+		}
+		if string(out) != want {
+			t.Errorf("size %d: decoded %q, want %q", size, out, want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Stats
+// ---------------------------------------------------------------------------
+
+func TestStats(t *testing.T) {
+	input := `<inv:Invoice xmlns:inv="urn:inv" xmlns:cbc="urn:cbc" version="2">
+		<cbc:ID>1</cbc:ID>
+		<Line><cbc:Qty unit="EA">1</cbc:Qty></Line>
+		<Line><cbc:Qty>2</cbc:Qty><Note><Text>x</Text></Note></Line>
+	</inv:Invoice>`
+	m, err := MapXML(strings.NewReader(input), RegisterNamespace("inv", "urn:inv"), RegisterNamespace("cbc", "urn:cbc"))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	st := Stats(m)
+	if st.Elements != 8 {
+		t.Errorf("Elements = %d, want 8", st.Elements)
+	}
+	if st.MaxDepth != 4 {
+		t.Errorf("MaxDepth = %d, want 4", st.MaxDepth)
+	}
+	if st.Attributes != 4 {
+		t.Errorf("Attributes = %d, want 4", st.Attributes)
+	}
+	wantTags := map[string]int{"inv:Invoice": 1, "cbc:ID": 1, "Line": 2, "cbc:Qty": 2, "Note": 1, "Text": 1}
+	if !reflect.DeepEqual(st.Tags, wantTags) {
+		t.Errorf("Tags = %v, want %v", st.Tags, wantTags)
+	}
+	wantNS := map[string]int{"inv": 1, "cbc": 3, "": 4}
+	if !reflect.DeepEqual(st.Namespaces, wantNS) {
+		t.Errorf("Namespaces = %v, want %v", st.Namespaces, wantNS)
+	}
+}
+
+func TestElementNames(t *testing.T) {
+	input := `<catalog>
+		<book id="1"><title>A</title><author><name>X</name></author><author><name>Y</name></author></book>
+		<book id="2"><title>B</title><tags><tag>t1</tag><tag>t2</tag><tag>t3</tag></tags></book>
+		<magazine><title>C</title></magazine>
+	</catalog>`
+	m, err := MapXML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	want := map[string]int{"catalog": 1, "book": 2, "magazine": 1, "title": 3, "author": 2, "name": 2, "tags": 1, "tag": 3}
+	if got := ElementNames(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("ElementNames = %v, want %v", got, want)
+	}
+	if got := ElementNames(NewMap()); len(got) != 0 {
+		t.Errorf("ElementNames(empty) = %v", got)
+	}
+}
+
+func BenchmarkLatin1Reader_ASCIIHeavy(b *testing.B) {
+	// Mostly ASCII with the occasional accented byte, like typical
+	// Latin-1 business documents.
+	line := []byte("<row><name>Jos\xe9 Mu\xf1oz</name><city>Bogota</city><amount>1000.00</amount></row>\n")
+	src := bytes.Repeat(line, 16*1024)
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := &latin1Reader{r: bytes.NewReader(src)}
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,76 +2,310 @@ package xml
 
 import (
 	"fmt"
+	"net/url"
 	"regexp"
 	"strconv"
+	"time"
+	"unicode/utf8"
 )
 
 // ============================================================================
 // VALIDATION ENGINE
 // ============================================================================
-// (Keep the Validate code the same as you had it, it is correct)
+
+// ValidationError describes a single Rule violation found by
+// ValidateDetailed. Kind is one of "required", "type", "min", "max",
+// "minlen", "maxlen", "regex", "badregex", "format" or "enum"; Actual holds
+// the offending value (nil for "required", the parsed number for
+// "min"/"max", the length for "minlen"/"maxlen", the compile error for
+// "badregex", a Regex that is not valid Go syntax).
+type ValidationError struct {
+	Path   string
+	Rule   Rule
+	Kind   string
+	Actual any
+}
+
+// Error returns the message Validate reports for this violation.
+func (e ValidationError) Error() string {
+	switch e.Kind {
+	case "required":
+		return "Missing: " + e.Path
+	case "type":
+		if e.Rule.Type == "array" {
+			return fmt.Sprintf("%s must be an array", e.Path)
+		}
+		return fmt.Sprintf("%s must be numeric", e.Path)
+	case "min":
+		return fmt.Sprintf("%s value %.2f is less than minimum %.2f", e.Path, e.Actual, e.Rule.Min)
+	case "max":
+		return fmt.Sprintf("%s value %.2f is greater than maximum %.2f", e.Path, e.Actual, e.Rule.Max)
+	case "minlen":
+		return fmt.Sprintf("%s length %v is less than minimum %d", e.Path, e.Actual, e.Rule.MinLen)
+	case "maxlen":
+		return fmt.Sprintf("%s length %v is greater than maximum %d", e.Path, e.Actual, e.Rule.MaxLen)
+	case "regex":
+		return fmt.Sprintf("%s invalid format (Regex)", e.Path)
+	case "badregex":
+		return fmt.Sprintf("%s cannot be checked: invalid Regex %q: %v", e.Path, e.Rule.Regex, e.Actual)
+	case "format":
+		if formatValidators[e.Rule.Format] == nil {
+			return fmt.Sprintf("%s unknown format %q", e.Path, e.Rule.Format)
+		}
+		return fmt.Sprintf("%s invalid format (%s)", e.Path, e.Rule.Format)
+	case "enum":
+		return fmt.Sprintf("%s invalid value. Allowed: %v", e.Path, e.Rule.Enum)
+	}
+	return fmt.Sprintf("%s failed %s check", e.Path, e.Kind)
+}
+
+// Validate checks data against rules and returns a message per violation.
+// See ValidateDetailed for the structured form.
 func Validate(data any, rules []Rule) []string {
 	var errs []string
+	for _, e := range ValidateDetailed(data, rules) {
+		errs = append(errs, e.Error())
+	}
+	return errs
+}
+
+// ValidateDetailed checks data against rules and returns every violation
+// with the rule that failed and the offending value, for callers that
+// build structured error responses.
+func ValidateDetailed(data any, rules []Rule) []ValidationError {
+	var errs []ValidationError
 	for _, r := range rules {
+		if r.When != nil && !r.When.holds(data) {
+			continue
+		}
 		val, err := Query(data, r.Path)
 		if err != nil {
 			if r.Required {
-				errs = append(errs, "Missing: "+r.Path)
+				errs = append(errs, ValidationError{Path: r.Path, Rule: r, Kind: "required"})
 			}
 			continue
 		}
-		var floatVal float64
-		var strVal string
-		isNum := false
-		isStr := false
-		switch r.Type {
-		case "array":
-			if _, ok := val.([]any); !ok {
-				errs = append(errs, fmt.Sprintf("%s must be an array", r.Path))
+		errs = append(errs, checkRule(r, val)...)
+	}
+	return errs
+}
+
+// Schema describes an element and, through Children, the structure below
+// it. Leaf constraints (Type, Min, Max, HasMin, HasMax, MinLen, MaxLen,
+// Format, Regex, Enum) mean the same as in Rule. Attributes are children
+// named "@attr".
+//
+//	schema := &Schema{Name: "invoice", Required: true, Children: []*Schema{
+//	    {Name: "@id", Required: true},
+//	    {Name: "line", Required: true, Repeated: true, Children: []*Schema{
+//	        {Name: "qty", Required: true, Type: "int", Min: 1},
+//	    }},
+//	}}
+type Schema struct {
+	Name      string
+	Required  bool
+	Repeated  bool // May occur more than once
+	MinOccurs int  // With Repeated: minimum occurrences (0 = no bound)
+	MaxOccurs int  // With Repeated: maximum occurrences (0 = unbounded)
+	Children  []*Schema
+
+	Type   string
+	Min    float64
+	Max    float64
+	HasMin bool
+	HasMax bool
+	Regex  string
+	Enum   []string
+	MinLen int
+	MaxLen int
+	Format string
+}
+
+// ValidateSchema checks data (as returned by MapXML) against schema, whose
+// Name is the root element, and returns every violation found. Errors
+// carry the element path, with the index of repeated elements
+// ("invoice/line[1]/qty"). Elements not declared in the schema are ignored.
+func ValidateSchema(data any, schema *Schema) []error {
+	var errs []error
+	validateSchemaNode(data, schema, "", &errs)
+	return errs
+}
+
+// validateSchemaNode validates the s child of parent, found at prefix.
+func validateSchemaNode(parent any, s *Schema, prefix string, errs *[]error) {
+	path := s.Name
+	if prefix != "" {
+		path = prefix + "/" + s.Name
+	}
+
+	var val any
+	switch p := parent.(type) {
+	case *OrderedMap:
+		val = p.Get(s.Name)
+	case map[string]any:
+		val = p[s.Name]
+	}
+	if val == nil {
+		if s.Required {
+			*errs = append(*errs, fmt.Errorf("%s: missing", path))
+		}
+		return
+	}
+
+	items := []any{val}
+	if list, ok := val.([]any); ok {
+		items = list
+	}
+	switch n := len(items); {
+	case !s.Repeated && n > 1:
+		*errs = append(*errs, fmt.Errorf("%s: occurs %d times, want at most 1", path, n))
+		return
+	case s.MaxOccurs > 0 && n > s.MaxOccurs:
+		*errs = append(*errs, fmt.Errorf("%s: occurs %d times, want at most %d", path, n, s.MaxOccurs))
+	case n < s.MinOccurs:
+		*errs = append(*errs, fmt.Errorf("%s: occurs %d times, want at least %d", path, n, s.MinOccurs))
+	}
+
+	for i, item := range items {
+		itemPath := path
+		if len(items) > 1 {
+			itemPath = fmt.Sprintf("%s[%d]", path, i)
+		}
+		// Regex and Enum apply without a Type, as in Rule
+		if s.Type != "" || s.Regex != "" || len(s.Enum) > 0 {
+			leaf := item
+			if om, ok := item.(*OrderedMap); ok {
+				leaf = om.Get("#text")
 			}
-		case "int", "float":
-			if v, ok := asFloat(val); ok {
-				floatVal = v
-				isNum = true
-			} else {
-				errs = append(errs, fmt.Sprintf("%s must be numeric", r.Path))
+			rule := Rule{Path: itemPath, Type: s.Type, Min: s.Min, Max: s.Max, HasMin: s.HasMin, HasMax: s.HasMax,
+				Regex: s.Regex, Enum: s.Enum, MinLen: s.MinLen, MaxLen: s.MaxLen, Format: s.Format}
+			for _, e := range checkRule(rule, leaf) {
+				*errs = append(*errs, e)
 			}
-		case "string":
-			strVal = fmt.Sprintf("%v", val)
-			isStr = true
 		}
-		if isNum {
-			if r.Min != 0 && floatVal < r.Min {
-				errs = append(errs, fmt.Sprintf("%s value %.2f is less than minimum %.2f", r.Path, floatVal, r.Min))
-			}
-			if r.Max != 0 && floatVal > r.Max {
-				errs = append(errs, fmt.Sprintf("%s value %.2f is greater than maximum %.2f", r.Path, floatVal, r.Max))
+		for _, child := range s.Children {
+			validateSchemaNode(item, child, itemPath, errs)
+		}
+	}
+}
+
+// holds evaluates the condition against data; a missing path never holds.
+func (c *Condition) holds(data any) bool {
+	val, err := Query(data, c.Path)
+	if err != nil {
+		return false
+	}
+	return filterText(val) == c.Equals
+}
+
+// checkRule applies the type and value constraints of r (everything but
+// Path and Required) to val, which was found at r.Path.
+func checkRule(r Rule, val any) []ValidationError {
+	var errs []ValidationError
+	fail := func(kind string, actual any) {
+		errs = append(errs, ValidationError{Path: r.Path, Rule: r, Kind: kind, Actual: actual})
+	}
+	var floatVal float64
+	var strVal string
+	isNum := false
+	isStr := false
+	switch r.Type {
+	case "array":
+		if list, ok := val.([]any); !ok {
+			fail("type", val)
+		} else {
+			checkLength(r, len(list), fail)
+		}
+	case "int", "float":
+		if v, ok := asFloat(val); ok {
+			floatVal = v
+			isNum = true
+		} else {
+			fail("type", val)
+		}
+	case "string":
+		strVal = fmt.Sprintf("%v", val)
+		isStr = true
+	}
+	if isNum {
+		if (r.Min != 0 || r.HasMin) && floatVal < r.Min {
+			fail("min", floatVal)
+		}
+		if (r.Max != 0 || r.HasMax) && floatVal > r.Max {
+			fail("max", floatVal)
+		}
+	}
+	if isStr {
+		checkLength(r, utf8.RuneCountInString(strVal), fail)
+		if r.Format != "" {
+			if valid := formatValidators[r.Format]; valid == nil || !valid(strVal) {
+				fail("format", strVal)
 			}
 		}
-		if isStr {
-			if r.Regex != "" {
-				matched, _ := regexp.MatchString(r.Regex, strVal)
-				if !matched {
-					errs = append(errs, fmt.Sprintf("%s invalid format (Regex)", r.Path))
-				}
+	}
+	// Like Enum, an untyped Regex checks the element's text.
+	if r.Regex != "" && (isStr || r.Type == "") {
+		text := strVal
+		if !isStr {
+			text = filterText(val)
+		}
+		if re, err := regexp.Compile(r.Regex); err != nil {
+			fail("badregex", err)
+		} else if !re.MatchString(text) {
+			fail("regex", text)
+		}
+	}
+	// Enum applies to any type but arrays; numbers match numerically
+	// ("1.50" is allowed by "1.5").
+	if len(r.Enum) > 0 && r.Type != "array" {
+		text := filterText(val)
+		found := false
+		for _, allowed := range r.Enum {
+			if text == allowed {
+				found = true
+				break
 			}
-			if len(r.Enum) > 0 {
-				found := false
-				for _, allowed := range r.Enum {
-					if strVal == allowed {
-						found = true
-						break
-					}
-				}
-				if !found {
-					errs = append(errs, fmt.Sprintf("%s invalid value. Allowed: %v", r.Path, r.Enum))
-				}
+			if n, err := strconv.ParseFloat(allowed, 64); err == nil && isNum && n == floatVal {
+				found = true
+				break
 			}
 		}
+		if !found {
+			fail("enum", text)
+		}
 	}
 	return errs
 }
 
+// checkLength applies MinLen/MaxLen to a string or array length n.
+func checkLength(r Rule, n int, fail func(kind string, actual any)) {
+	if r.MinLen > 0 && n < r.MinLen {
+		fail("minlen", n)
+	}
+	if r.MaxLen > 0 && n > r.MaxLen {
+		fail("maxlen", n)
+	}
+}
+
+var (
+	emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s.]+$`)
+	uuidRegex  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// formatValidators are the named formats accepted by Rule.Format.
+var formatValidators = map[string]func(string) bool{
+	"email": emailRegex.MatchString,
+	"uuid":  uuidRegex.MatchString,
+	"url": func(s string) bool {
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	},
+}
+
 func asFloat(v any) (float64, bool) {
 	switch i := v.(type) {
 	case int:
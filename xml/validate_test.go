@@ -1,6 +1,9 @@
 package xml
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 // ============================================================================
 // VALIDATION TESTS
@@ -70,6 +73,34 @@ func TestValidate_Rules(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "Fail Zero Max",
+			rules: []Rule{
+				{Path: "user/age", Type: "int", Max: 0, HasMax: true},
+			},
+			wantError: true,
+		},
+		{
+			name: "Fail Numeric Enum",
+			rules: []Rule{
+				{Path: "user/age", Type: "int", Enum: []string{"18", "21"}},
+			},
+			wantError: true,
+		},
+		{
+			name: "Success Numeric Enum",
+			rules: []Rule{
+				{Path: "user/age", Type: "int", Enum: []string{"25.0"}},
+			},
+			wantError: false,
+		},
+		{
+			name: "Fail Invalid Regex",
+			rules: []Rule{
+				{Path: "user/name", Regex: `[`, Type: "string"},
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -84,3 +115,180 @@ func TestValidate_Rules(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSchema(t *testing.T) {
+	schema := &Schema{Name: "invoice", Required: true, Children: []*Schema{
+		{Name: "@id", Required: true},
+		{Name: "customer", Required: true, Children: []*Schema{
+			{Name: "nit", Required: true, Type: "string", Regex: `^[0-9]+$`},
+		}},
+		{Name: "line", Required: true, Repeated: true, Children: []*Schema{
+			{Name: "qty", Required: true, Type: "int", Min: 1},
+			{Name: "price", Type: "float"},
+		}},
+		{Name: "currency", Type: "string", Enum: []string{"COP", "USD"}},
+	}}
+
+	valid := `<invoice id="7"><customer><nit>900123</nit></customer>
+		<line><qty>2</qty><price>10.5</price></line><line><qty>1</qty></line>
+		<currency>COP</currency></invoice>`
+	m, _ := MapXML(strings.NewReader(valid))
+	if errs := ValidateSchema(m, schema); len(errs) != 0 {
+		t.Errorf("valid document reported errors: %v", errs)
+	}
+
+	invalid := `<invoice><customer><name>ACME</name></customer>
+		<line><qty>2</qty></line><line><qty>many</qty><price>x</price></line>
+		<currency>EUR</currency></invoice>`
+	m, _ = MapXML(strings.NewReader(invalid))
+	var got []string
+	for _, err := range ValidateSchema(m, schema) {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"invoice/@id: missing",
+		"invoice/customer/nit: missing",
+		"invoice/line[1]/qty must be numeric",
+		"invoice/line[1]/price must be numeric",
+		"invoice/currency invalid value. Allowed: [COP USD]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Enum and Regex need no Type
+	untyped := &Schema{Name: "invoice", Children: []*Schema{
+		{Name: "@currency", Enum: []string{"COP", "USD"}},
+		{Name: "number", Regex: `^SETT-\d+$`},
+	}}
+	m, _ = MapXML(strings.NewReader(`<invoice currency="EUR"><number>FACT-1</number></invoice>`))
+	got = nil
+	for _, err := range ValidateSchema(m, untyped) {
+		got = append(got, err.Error())
+	}
+	want = []string{
+		"invoice/@currency invalid value. Allowed: [COP USD]",
+		"invoice/number invalid format (Regex)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("untyped leaves:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	m, _ = MapXML(strings.NewReader(`<invoice id="1"><customer><nit>1</nit><nit>2</nit></customer><line><qty>1</qty></line></invoice>`))
+	if errs := ValidateSchema(m, schema); len(errs) != 1 || !strings.Contains(errs[0].Error(), "occurs 2 times") {
+		t.Errorf("repeated non-repeatable element: %v", errs)
+	}
+}
+
+func TestValidateDetailed(t *testing.T) {
+	data := map[string]any{
+		"user": map[string]any{"age": "15", "role": "root", "score": "high"},
+	}
+	rules := []Rule{
+		{Path: "user/name", Required: true},
+		{Path: "user/age", Type: "int", Min: 18},
+		{Path: "user/role", Type: "string", Enum: []string{"admin", "guest"}},
+		{Path: "user/score", Type: "float"},
+	}
+
+	errs := ValidateDetailed(data, rules)
+	want := []struct {
+		kind   string
+		actual any
+	}{
+		{"required", nil},
+		{"min", 15.0},
+		{"enum", "root"},
+		{"type", "high"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Kind != w.kind || errs[i].Actual != w.actual || errs[i].Rule.Path != rules[i].Path || errs[i].Path != rules[i].Path {
+			t.Errorf("error %d = %+v, want kind %q actual %v", i, errs[i], w.kind, w.actual)
+		}
+	}
+
+	// Validate keeps reporting the same messages
+	msgs := Validate(data, rules)
+	wantMsgs := []string{
+		"Missing: user/name",
+		"user/age value 15.00 is less than minimum 18.00",
+		"user/role invalid value. Allowed: [admin guest]",
+		"user/score must be numeric",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(wantMsgs, "\n") {
+		t.Errorf("Validate = %q, want %q", msgs, wantMsgs)
+	}
+}
+
+func TestValidate_LengthAndFormat(t *testing.T) {
+	data := map[string]any{
+		"user": map[string]any{
+			"name":    "Iñaki",
+			"email":   "ana@example.com",
+			"id":      "3f2b8c1e-9a4d-4e5f-8b6a-1c2d3e4f5a6b",
+			"site":    "https://example.com/x",
+			"born":    "1990-02-30",
+			"tags":    []any{"a", "b", "c"},
+			"zip":     "12",
+			"badMail": "ana@",
+		},
+	}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want string // "" = valid
+	}{
+		{"runes not bytes", Rule{Path: "user/name", Type: "string", MaxLen: 5}, ""},
+		{"too short", Rule{Path: "user/zip", Type: "string", MinLen: 5}, "user/zip length 2 is less than minimum 5"},
+		{"too long", Rule{Path: "user/name", Type: "string", MaxLen: 3}, "user/name length 5 is greater than maximum 3"},
+		{"array length", Rule{Path: "user/tags", Type: "array", MaxLen: 2}, "user/tags length 3 is greater than maximum 2"},
+		{"no type, no check", Rule{Path: "user/zip", MinLen: 5}, ""},
+		{"email", Rule{Path: "user/email", Type: "string", Format: "email"}, ""},
+		{"bad email", Rule{Path: "user/badMail", Type: "string", Format: "email"}, "user/badMail invalid format (email)"},
+		{"uuid", Rule{Path: "user/id", Type: "string", Format: "uuid"}, ""},
+		{"url", Rule{Path: "user/site", Type: "string", Format: "url"}, ""},
+		{"bad url", Rule{Path: "user/name", Type: "string", Format: "url"}, "user/name invalid format (url)"},
+		{"bad date", Rule{Path: "user/born", Type: "string", Format: "date"}, "user/born invalid format (date)"},
+		{"unknown format", Rule{Path: "user/name", Type: "string", Format: "iban"}, `user/name unknown format "iban"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(Validate(data, []Rule{tt.rule}), "; ")
+			if got != tt.want {
+				t.Errorf("Validate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_When(t *testing.T) {
+	rules := []Rule{
+		{Path: "order/shippingAddress", Required: true, When: &Condition{Path: "order/@type", Equals: "physical"}},
+		{Path: "order/email", Required: true, Type: "string", Format: "email", When: &Condition{Path: "order/@type", Equals: "digital"}},
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`<order type="physical"><shippingAddress>Calle 1</shippingAddress></order>`, ""},
+		{`<order type="physical"/>`, "Missing: order/shippingAddress"},
+		{`<order type="digital"><email>nope</email></order>`, "order/email invalid format (email)"},
+		{`<order type="digital"><email>a@b.co</email></order>`, ""},
+		{`<order><note>no type</note></order>`, ""}, // condition path missing: rules skipped
+	}
+	for _, tt := range tests {
+		m, err := MapXML(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("MapXML failed: %v", err)
+		}
+		if got := strings.Join(Validate(m, rules), "; "); got != tt.want {
+			t.Errorf("Validate(%s) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
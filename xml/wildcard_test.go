@@ -3,6 +3,7 @@ package xml
 import (
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -83,3 +84,74 @@ func TestQuery_Wildcard(t *testing.T) {
 		})
 	}
 }
+
+func TestQuery_NameMeta(t *testing.T) {
+	m, err := MapXML(strings.NewReader(`<invoice><items>
+		<box><sku>B1</sku></box>
+		<bag><sku>G1</sku></bag>
+		<box><sku>B2</sku></box>
+		<crate><sku>C1</sku></crate>
+	</items></invoice>`))
+	if err != nil {
+		t.Fatalf("MapXML failed: %v", err)
+	}
+
+	names, err := QueryAll(m, "invoice/items/*/#name")
+	if err != nil {
+		t.Fatalf("QueryAll error: %v", err)
+	}
+	want := []any{"box", "box", "bag", "crate"} // repeated tags are grouped by the parser
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("#name = %v, want %v", names, want)
+	}
+
+	// Filtered and deep-search results keep their names too
+	names, _ = QueryAll(m, "invoice/items/*[sku='G1']/#name")
+	if !reflect.DeepEqual(names, []any{"bag"}) {
+		t.Errorf("#name after filter = %v, want [bag]", names)
+	}
+	names, _ = QueryAll(m, "//crate/#name")
+	if !reflect.DeepEqual(names, []any{"crate"}) {
+		t.Errorf("#name after deep search = %v, want [crate]", names)
+	}
+}
+
+func TestQueryAllPairs(t *testing.T) {
+	data := map[string]any{
+		"invoice": map[string]any{
+			"items": map[string]any{
+				"box":   map[string]any{"sku": "SKU-BOX-001"},
+				"bag":   map[string]any{"sku": "SKU-BAG-002"},
+				"crate": map[string]any{"sku": "SKU-CRATE-003"},
+			},
+		},
+	}
+
+	pairs, err := QueryAllPairs(data, "invoice/items/*")
+	if err != nil {
+		t.Fatalf("QueryAllPairs error: %v", err)
+	}
+	got := map[string]string{}
+	for _, p := range pairs {
+		got[p.Key] = p.Value.(map[string]any)["sku"].(string)
+	}
+	want := map[string]string{"box": "SKU-BOX-001", "bag": "SKU-BAG-002", "crate": "SKU-CRATE-003"}
+	if len(pairs) != 3 || !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryAllPairs(*) = %v, want %v", pairs, want)
+	}
+
+	// Deep searches and unions keep the key of each result
+	pairs, _ = QueryAllPairs(data, "//sku | invoice/items/b*")
+	var keys []string
+	for _, p := range pairs {
+		keys = append(keys, p.Key)
+	}
+	sort.Strings(keys)
+	if got := strings.Join(keys, ","); got != "bag,box,sku,sku,sku" {
+		t.Errorf("keys = %s, want bag,box,sku,sku,sku", got)
+	}
+
+	if pairs, _ := QueryAllPairs(data, "invoice/missing"); len(pairs) != 0 {
+		t.Errorf("QueryAllPairs(missing) = %v, want none", pairs)
+	}
+}
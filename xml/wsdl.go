@@ -4,8 +4,10 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"net/http"
 	"sort"
 	"strings"
+	"time"
 )
 
 // ============================================================================
@@ -237,6 +239,25 @@ func ParseWSDL(r io.Reader) (*WSDL, error) {
 	return &WSDL{operations: ops}, nil
 }
 
+// FetchWSDL downloads a WSDL over HTTP(S) with client and parses it with
+// ParseWSDL. Pass a client configured with the timeout (and transport) the
+// environment needs; a nil client gets a 30-second timeout, the same default
+// as NewSoapClient, rather than http.DefaultClient, which never times out.
+func FetchWSDL(url string, client *http.Client) (*WSDL, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("wsdl: fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("wsdl: fetch %s: HTTP %d", url, resp.StatusCode)
+	}
+	return ParseWSDL(resp.Body)
+}
+
 // localName strips a "prefix:" from a QName-shaped string, matching by local
 // name only (see the scope note at the top of this file).
 func localName(qname string) string {
@@ -1,8 +1,13 @@
 package xml
 
 import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 // testWSDL is hand-authored, representative of a typical ASMX/.NET-generated
@@ -205,3 +210,33 @@ func TestWSDL_Endpoint_NoSOAPPort(t *testing.T) {
 		t.Fatal("expected error for a WSDL with no SOAP port, got nil")
 	}
 }
+
+func TestFetchWSDL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testWSDL))
+	}))
+	defer ts.Close()
+
+	w, err := FetchWSDL(ts.URL+"?wsdl", nil)
+	if err != nil {
+		t.Fatalf("FetchWSDL failed: %v", err)
+	}
+	if len(w.Operations()) == 0 {
+		t.Error("FetchWSDL returned no operations")
+	}
+}
+
+func TestFetchWSDL_ClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	_, err := FetchWSDL(ts.URL, &http.Client{Timeout: 20 * time.Millisecond})
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("FetchWSDL against a slow server = %v, want a timeout error", err)
+	}
+}
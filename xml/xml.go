@@ -1,10 +1,14 @@
 package xml
 
 import (
+	"bytes"
 	"encoding/xml"
 	"io"
+	"maps"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // ============================================================================
@@ -12,26 +16,86 @@ import (
 // ============================================================================
 
 type config struct {
-	forceArrayKeys map[string]bool             // Tags that will always be treated as a list
-	namespaces     map[string]string           // Namespace Aliases
-	valueHooks     map[string]func(string) any // Transformation Hooks
+	forceArrayKeys map[string]bool              // Tags that will always be treated as a list
+	namespaces     map[string]string            // Namespace Aliases
+	valueHooks     map[string]func(string) any  // Transformation Hooks
+	attrDefaults   map[string]map[string]string // Tag -> attribute defaults
+	splitAttrs     map[string]string            // Attribute name -> token separator
+	collapseTo     map[string]string            // Tag -> child that replaces it
 
 	// Flags
-	isLenient        bool // Tolerant mode for dirty HTML/XML
-	inferTypes       bool // Automatic type inference (int, bool, float)
-	isSoupMode       bool // "Soup Mode" (Dirty HTML - Normalization & Sanitization)
-	useCharsetReader bool // Use charset reader for ISO-8859-1 and Windows-1252
-	prettyPrint      bool // Indentation output
-	htmlAutoClose    []string
+	isLenient           bool   // Tolerant mode for dirty HTML/XML
+	inferTypes          bool   // Automatic type inference (int, bool, float)
+	isSoupMode          bool   // "Soup Mode" (Dirty HTML - Normalization & Sanitization)
+	useCharsetReader    bool   // Use charset reader for ISO-8859-1 and Windows-1252
+	prettyPrint         bool   // Indentation output
+	commentOrder        bool   // Record comments (and their siblings) in #seq
+	normalizeEOL        bool   // Convert \r\n and \r to \n in char data
+	normalizeAttrs      bool   // Turn tabs and line breaks in attribute values into spaces
+	keepCDATA           bool   // Store text read from CDATA sections under #cdata
+	simplifyIgnoreAttrs bool   // Collapse text nodes to scalars even when they carry attributes
+	rawNodes            bool   // Never collapse elements to scalars
+	stringifyLeaves     bool   // Keep every leaf a string (no inference, hooks, splitting or collapsing)
+	attrGroupKey        string // Collect attributes under this key ("" = "@" siblings)
+	htmlAutoClose       []string
+	boolTruthy          []string // Custom boolean literals (nil = defaults)
+	boolFalsy           []string
+
+	textJoiner func(prev, next string) string // Combines the text runs of an element (nil = concatenate)
+
+	// Encoder value formatting
+	omitEmptyAttrs bool                // Skip attributes whose value renders as ""
+	nsAfterAttrs   bool                // Write root xmlns declarations after the attributes
+	writeBOM       bool                // Prefix Encoder output with the UTF-8 BOM
+	finalNewline   bool                // End each encoded document with "\n"
+	timeLayout     string              // Layout for time.Time values ("" = RFC3339)
+	bytesEncoder   func([]byte) string // Encoding for []byte values (nil = base64)
+	boolText       []string            // Text for true and false values (nil = "true"/"false")
+	htmlOutput     bool                // Serialize as HTML (void elements, raw script/style)
 }
 
 type Option func(*config)
 
+var (
+	globalOptionsMu sync.RWMutex
+	globalOptions   []Option
+)
+
+// SetDefaultOptions registers options applied before the per-call options
+// of every MapXML, NewDecoder, NewStream, Walk, IsWellFormed and NewEncoder
+// call, so per-call options override them. It changes package-level state for the
+// whole program: call it once at startup. Calling it again replaces the
+// previous defaults; SetDefaultOptions() with no arguments clears them.
+//
+//	xml.SetDefaultOptions(xml.EnableLegacyCharsets(), xml.ForceArray("item"))
+func SetDefaultOptions(opts ...Option) {
+	globalOptionsMu.Lock()
+	defer globalOptionsMu.Unlock()
+	globalOptions = append([]Option(nil), opts...)
+}
+
+// newConfig builds the configuration for a call: defaults, then the
+// SetDefaultOptions ones, then opts.
+func newConfig(opts []Option) *config {
+	cfg := defaultConfig()
+	globalOptionsMu.RLock()
+	for _, opt := range globalOptions {
+		opt(cfg)
+	}
+	globalOptionsMu.RUnlock()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
 func defaultConfig() *config {
 	return &config{
 		forceArrayKeys:   make(map[string]bool),
 		namespaces:       make(map[string]string),
 		valueHooks:       make(map[string]func(string) any),
+		attrDefaults:     make(map[string]map[string]string),
+		collapseTo:       make(map[string]string),
 		useCharsetReader: false,
 	}
 }
@@ -62,17 +126,155 @@ func WithValueHook(tagName string, fn func(string) any) Option {
 	return func(c *config) { c.valueHooks[tagName] = fn }
 }
 
+// CollapseToChild replaces every tag element by the value of its childKey
+// child, flattening wrappers such as <amount><value>100</value></amount>
+// into amount = "100". Elements without that child are left as they are.
+func CollapseToChild(tag, childKey string) Option {
+	return func(c *config) { c.collapseTo[tag] = childKey }
+}
+
+// WithAttrDefaults injects default attribute values into elements of the
+// given tag that do not carry them (e.g. currencyID="COP").
+// Attribute names are given without the "@" prefix.
+func WithAttrDefaults(tag string, defaults map[string]string) Option {
+	return func(c *config) {
+		if c.attrDefaults[tag] == nil {
+			c.attrDefaults[tag] = make(map[string]string)
+		}
+		for k, v := range defaults {
+			c.attrDefaults[tag][k] = v
+		}
+	}
+}
+
 // EnableExperimental enables Soup Mode (for dirty HTML).
 func EnableExperimental() Option {
 	return func(c *config) {
 		c.inferTypes = true
 		c.isLenient = true
 		c.isSoupMode = true
-		c.htmlAutoClose = []string{
-			"area", "base", "br", "col", "embed", "hr", "img", "input",
-			"link", "meta", "param", "source", "track", "wbr",
-			"command", "keygen", "menuitem",
-		}
+		c.htmlAutoClose = htmlVoidElements
+	}
+}
+
+// htmlVoidElements are the HTML elements that have no content and no end
+// tag.
+var htmlVoidElements = []string{
+	"area", "base", "br", "col", "embed", "hr", "img", "input",
+	"link", "meta", "param", "source", "track", "wbr",
+	"command", "keygen", "menuitem",
+}
+
+// PreserveCDATA remembers which text came from CDATA sections: an element
+// whose text is written as <![CDATA[...]]> keeps it, untrimmed and without
+// type inference, under "#cdata" (so it stays in map form, {"#cdata": "..."})
+// and the Encoder wraps it in CDATA again, while plain text is stored and
+// written as usual. A run mixing plain text and CDATA counts as plain text.
+// Not available together with EnableLegacyCharsets, where all text goes to
+// "#text".
+func PreserveCDATA() Option {
+	return func(c *config) { c.keepCDATA = true }
+}
+
+// WithTextJoiner sets how the text runs of an element are combined into its
+// "#text" when child elements split them: <p>One<br>Two</p> reads "OneTwo"
+// by default (each run trimmed, then concatenated). fn receives the text so
+// far and the next trimmed run, e.g. joining with " " or "\n":
+//
+//	xml.WithTextJoiner(func(prev, next string) string { return prev + " " + next })
+func WithTextJoiner(fn func(prev, next string) string) Option {
+	return func(c *config) { c.textJoiner = fn }
+}
+
+// PreserveCommentOrder keeps XML comments and processing instructions inside
+// elements instead of discarding them. Every element that contains one gets
+// a "#seq" list describing its content in document order, so they keep their
+// position relative to sibling elements and text. Each entry is a single-key
+// *OrderedMap: {"#comment": c}, {"#pi": "target data"}, {"#text": t} or
+// {tagName: value}. The Encoder replays "#seq" when present. A DOCTYPE (or
+// other prolog directive) is kept too, under a root "#directive" key, and
+// written back before the root element.
+func PreserveCommentOrder() Option {
+	return func(c *config) { c.commentOrder = true }
+}
+
+// NormalizeLineEndings converts "\r\n" and lone "\r" to "\n" in text
+// content before it is stored. encoding/xml already normalizes literal line
+// breaks, but carriage returns written as character references (&#13;, as
+// some Windows exporters do) reach "#text" untouched without this option.
+func NormalizeLineEndings() Option {
+	return func(c *config) { c.normalizeEOL = true }
+}
+
+// NormalizeAttributeValues applies XML attribute-value normalization to
+// attributes: every tab or line break becomes a single space, while other
+// whitespace (leading, trailing, runs of spaces) is kept. encoding/xml
+// expands character references before the parser sees them, so an escaped
+// &#9; or &#10; is normalized too; that is why this is opt-in, as the
+// Encoder escapes such characters precisely so they round-trip.
+func NormalizeAttributeValues() Option {
+	return func(c *config) { c.normalizeAttrs = true }
+}
+
+// SimplifyIgnoringAttrs drops attributes from text-bearing elements so they
+// collapse to their text, like elements without attributes do:
+// <price currency="COP">1000</price> becomes "1000" instead of
+// {"@currency": "COP", "#text": "1000"}. Elements with child elements, or
+// with attributes but no text, keep their map form.
+func SimplifyIgnoringAttrs() Option {
+	return func(c *config) { c.simplifyIgnoreAttrs = true }
+}
+
+// WithRawNodes disables node simplification: every element stays an
+// *OrderedMap, text-only ones included (<a>x</a> becomes {"#text": "x"},
+// <a/> an empty map), so code walking the tree sees uniform node types.
+// Type inference and value hooks still apply to the "#text" value. Note
+// that encoding/xml reports <a/> and <a></a> identically, so they still
+// parse the same. Takes precedence over SimplifyIgnoringAttrs.
+func WithRawNodes() Option {
+	return func(c *config) { c.rawNodes = true }
+}
+
+// StringifyLeaves gives parsed documents a fully predictable shape for
+// generic code: every element is an *OrderedMap (as with WithRawNodes) and
+// every leaf ("#text", "#cdata", attributes) is a string. Type inference,
+// value hooks (WithValueHook), SplitAttributes and CollapseToChild are
+// ignored.
+func StringifyLeaves() Option {
+	return func(c *config) {
+		c.rawNodes = true
+		c.stringifyLeaves = true
+	}
+}
+
+// GroupAttributes collects the attributes of each element into a single
+// child map under key instead of "@"-prefixed siblings, with the "@"
+// dropped: <book id="1" lang="en"> becomes {"_attrs": {"id": "1", "lang": "en"}}
+// for GroupAttributes("_attrs"). Handy for JSON mapping; the Encoder does
+// not turn the group back into attributes.
+func GroupAttributes(key string) Option {
+	return func(c *config) { c.attrGroupKey = key }
+}
+
+// SplitAttributes turns multi-valued attributes into []any of tokens. It
+// maps attribute name (as stored, without "@") to its separator; " " splits
+// on any run of whitespace, as HTML does for class and rel:
+//
+//	SplitAttributes(map[string]string{"class": " "}) // @class = [a b c]
+//
+// The Encoder joins such lists back with single spaces.
+func SplitAttributes(attrs map[string]string) Option {
+	return func(c *config) { c.splitAttrs = attrs }
+}
+
+// WithBoolLiterals replaces the literals recognized as booleans, both by
+// type inference (EnableExperimental) and by AsBool. Matching is
+// case-insensitive. Without it, inference only accepts "true"/"false" and
+// AsBool keeps its wider truthy set (true, 1, yes, on, ok).
+func WithBoolLiterals(truthy, falsy []string) Option {
+	return func(c *config) {
+		c.boolTruthy = truthy
+		c.boolFalsy = falsy
 	}
 }
 
@@ -81,25 +283,295 @@ func WithPrettyPrint() Option {
 	return func(c *config) { c.prettyPrint = true }
 }
 
+// WithOmitEmptyAttributes makes the Encoder skip attributes whose value is
+// empty, instead of writing attr="" (the default).
+func WithOmitEmptyAttributes() Option {
+	return func(c *config) { c.omitEmptyAttrs = true }
+}
+
+// WithNamespacesAfterAttributes makes the Encoder write the root element's
+// xmlns declarations (from RegisterNamespace) after its own attributes
+// instead of before them (the default):
+//
+//	<Invoice id="7" xmlns:cbc="...">  vs  <Invoice xmlns:cbc="..." id="7">
+func WithNamespacesAfterAttributes() Option {
+	return func(c *config) { c.nsAfterAttrs = true }
+}
+
+// WithBOM makes the Encoder write the UTF-8 byte order mark (EF BB BF)
+// before the document, for consumers such as Excel that rely on it to
+// detect the encoding. It is written once per Encoder, even if Encode is
+// called several times.
+func WithBOM() Option {
+	return func(c *config) { c.writeBOM = true }
+}
+
+// WithTrailingNewline makes the Encoder end every document it writes with
+// a single "\n", as POSIX text files expect.
+func WithTrailingNewline() Option {
+	return func(c *config) { c.finalNewline = true }
+}
+
+// WithTimeLayout sets the layout the Encoder uses for time.Time values
+// (default time.RFC3339).
+func WithTimeLayout(layout string) Option {
+	return func(c *config) { c.timeLayout = layout }
+}
+
+// WithBooleanFormat sets how the Encoder writes bool values, e.g. "1" and
+// "0" for schemas that require the numeric xs:boolean form (default "true"
+// and "false").
+func WithBooleanFormat(trueStr, falseStr string) Option {
+	return func(c *config) { c.boolText = []string{trueStr, falseStr} }
+}
+
+// WithHTMLOutput makes the Encoder write HTML instead of XML, for
+// re-rendering pages parsed with EnableExperimental: void elements are
+// written as <br> (no end tag, content ignored), the text of <script> and
+// <style> is written unescaped, and boolean attributes such as disabled or
+// checked are written by name alone (omitted when false).
+func WithHTMLOutput() Option {
+	return func(c *config) { c.htmlOutput = true }
+}
+
+// WithBytesEncoder sets how the Encoder writes []byte values
+// (default standard base64, e.g. hex.EncodeToString for hexBinary).
+func WithBytesEncoder(fn func([]byte) string) Option {
+	return func(c *config) { c.bytesEncoder = fn }
+}
+
 // ============================================================================
 // 2. PARSER CORE
 // ============================================================================
 
 type node struct {
-	tagName string
-	data    *OrderedMap
+	tagName    string
+	data       *OrderedMap // nil while the element has only text (see text)
+	text       string      // Accumulated text of a node without data
+	run        textRun     // Text since the last element boundary
+	seq        []any       // Content in document order (PreserveCommentOrder only)
+	hasComment bool
+}
+
+// textRun accumulates the CharData fragments of one run of text, i.e.
+// everything between two element boundaries. encoding/xml may split a run
+// (around CDATA sections and comments); the fragments are joined exactly as
+// they came and only the run as a whole is trimmed, so "A <![CDATA[&]]> B"
+// reads "A & B" and "x<!-- c -->y" reads "xy".
+type textRun struct {
+	sb    strings.Builder
+	cdata strings.Builder // CDATA fragments only (PreserveCDATA)
+	plain bool            // A non-blank fragment was plain text
+}
+
+// add appends a fragment; isCDATA tells whether it was a CDATA section,
+// which is only known with PreserveCDATA.
+func (t *textRun) add(b []byte, isCDATA bool) {
+	t.sb.Write(b)
+	if isCDATA {
+		t.cdata.Write(b)
+	} else if !t.plain && len(bytes.TrimSpace(b)) > 0 {
+		t.plain = true
+	}
+}
+
+// flush returns the run and starts a new one. A run made only of CDATA
+// sections (and blanks) is returned untrimmed with isCDATA set; anything
+// else is the trimmed text.
+func (t *textRun) flush(cfg *config) (text string, isCDATA bool) {
+	if t.sb.Len() == 0 {
+		return "", false
+	}
+	content := t.sb.String()
+	if !t.plain && t.cdata.Len() > 0 {
+		content, isCDATA = t.cdata.String(), true
+	}
+	t.sb.Reset()
+	t.cdata.Reset()
+	t.plain = false
+	if cfg.normalizeEOL {
+		content = normalizeLineEndings(content)
+	}
+	if isCDATA {
+		return content, true
+	}
+	return strings.TrimSpace(content), false
+}
+
+// flushText stores the node's pending text run as #text (or #cdata, see
+// PreserveCDATA), appending to any earlier run (see WithTextJoiner), and
+// records it in #seq.
+func (n *node) flushText(cfg *config) {
+	text, isCDATA := n.run.flush(cfg)
+	if text == "" {
+		return
+	}
+	key := "#text"
+	if isCDATA {
+		key = "#cdata"
+		n.materialize()
+	}
+	if n.data == nil {
+		n.text = joinText(cfg, n.text, text)
+	} else if existing := n.data.Get(key); existing != nil {
+		n.data.Put(key, joinText(cfg, existing.(string), text))
+	} else {
+		n.data.Put(key, text)
+	}
+	if cfg.commentOrder {
+		n.seq = append(n.seq, seqEntry(key, text))
+	}
+}
+
+// joinText appends the text run next to prev.
+func joinText(cfg *config, prev, next string) string {
+	if prev == "" || cfg.textJoiner == nil {
+		return prev + next
+	}
+	return cfg.textJoiner(prev, next)
+}
+
+// materialize returns the node's map, allocating it (with any text
+// accumulated so far) the first time the element needs more than text.
+// Attribute-less leaves, the bulk of data documents, never get one.
+func (n *node) materialize() *OrderedMap {
+	if n.data == nil {
+		n.data = NewMap()
+		if n.text != "" {
+			n.data.Put("#text", n.text)
+			n.text = ""
+		}
+	}
+	return n.data
+}
+
+// seqEntry builds a single-key #seq entry.
+func seqEntry(key string, value any) *OrderedMap {
+	e := NewMap()
+	e.Put(key, value)
+	return e
+}
+
+// Decoder parses XML documents into OrderedMaps with a fixed configuration.
+// Options are resolved once in NewDecoder, so a single Decoder can be reused
+// (also concurrently) for many documents.
+type Decoder struct {
+	cfg *config
+}
+
+// NewDecoder creates a configured decoder (the counterpart of NewEncoder).
+func NewDecoder(opts ...Option) *Decoder {
+	cfg := newConfig(opts)
+	return &Decoder{cfg: cfg}
+}
+
+// Decode reads one XML document from r into a deterministic OrderedMap.
+func (d *Decoder) Decode(r io.Reader) (*OrderedMap, error) {
+	return decodeMap(r, d.cfg)
 }
 
 // MapXML reads XML into a deterministic OrderedMap.
 func MapXML(r io.Reader, opts ...Option) (*OrderedMap, error) {
-	cfg := defaultConfig()
-	for _, opt := range opts {
-		opt(cfg)
+	return NewDecoder(opts...).Decode(r)
+}
+
+// MapXMLFile opens the file at path, parses it with MapXML and closes it.
+// Gzip-compressed files are decompressed transparently; the encoding is
+// still taken from the XML declaration (see EnableLegacyCharsets).
+//
+//	m, err := xml.MapXMLFile("invoice.xml.gz", xml.EnableLegacyCharsets())
+func MapXMLFile(path string, opts ...Option) (*OrderedMap, error) {
+	f, err := openXMLFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return MapXML(f, opts...)
+}
+
+// MapXMLRoot is MapXML without the root wrapper key: it returns the content
+// of the root element directly, so "<user><name>x</name></user>" is queried
+// as "name" instead of "user/name". A root holding only text is returned as
+// a map with a single "#text" key.
+func MapXMLRoot(r io.Reader, opts ...Option) (*OrderedMap, error) {
+	m, err := MapXML(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return rootContent(m), nil
+}
+
+// rootContent returns the content of the root element of a parsed document
+// (see MapXMLRoot).
+func rootContent(m *OrderedMap) *OrderedMap {
+	for _, k := range m.Keys() {
+		if strings.HasPrefix(k, "#") || strings.HasPrefix(k, "@") {
+			continue
+		}
+		if content, ok := m.Get(k).(*OrderedMap); ok {
+			return content
+		}
+		content := NewMap()
+		content.Put("#text", m.Get(k))
+		return content
+	}
+	return NewMap()
+}
+
+// IsWellFormed reads r to the end without building a tree and returns the
+// first syntax error (a *SyntaxError with line information) or nil. Parse
+// options that affect tokenizing (lenient mode, legacy charsets) apply; in
+// soup mode errors are reported rather than skipped.
+func IsWellFormed(r io.Reader, opts ...Option) error {
+	cfg := newConfig(opts)
+	decoder := newTokenDecoder(r, cfg, nil)
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return wrapError(err)
+		}
+	}
+}
+
+// cdataTracker records the raw bytes read by the decoder that have not been
+// tokenized yet, so a CharData token can be told apart from a CDATA section
+// (encoding/xml reports both alike).
+type cdataTracker struct {
+	r    io.Reader
+	buf  []byte
+	base int64 // Input offset of buf[0]
+}
+
+func (t *cdataTracker) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.buf = append(t.buf, p[:n]...)
+	return n, err
+}
+
+// isCDATA reports whether the token between the input offsets from and to
+// is a CDATA section, and releases the bytes before to.
+func (t *cdataTracker) isCDATA(from, to int64) bool {
+	if from < t.base || to < from || to-t.base > int64(len(t.buf)) {
+		return false
 	}
+	ok := bytes.HasPrefix(t.buf[from-t.base:to-t.base], []byte("<![CDATA["))
+	t.buf = t.buf[to-t.base:]
+	t.base = to
+	return ok
+}
 
+// newTokenDecoder builds the encoding/xml decoder for cfg. A non-nil
+// tracker is fed the input as the decoder reads it.
+func newTokenDecoder(r io.Reader, cfg *config, tracker *cdataTracker) *xml.Decoder {
 	if cfg.isSoupMode {
 		r = sanitizeSoup(r)
 	}
+	if tracker != nil {
+		tracker.r = r
+		r = tracker
+	}
 
 	decoder := xml.NewDecoder(r)
 	if cfg.isLenient {
@@ -110,18 +582,220 @@ func MapXML(r io.Reader, opts ...Option) (*OrderedMap, error) {
 	if cfg.useCharsetReader {
 		decoder.CharsetReader = charsetReader
 	}
+	return decoder
+}
+
+// decodeMap is the parser core shared by MapXML and Decoder.
+func decodeMap(r io.Reader, cfg *config) (*OrderedMap, error) {
+	var tracker *cdataTracker
+	if cfg.keepCDATA && !cfg.useCharsetReader {
+		tracker = &cdataTracker{}
+	}
+	return buildMap(newTokenSource(r, cfg, tracker), cfg)
+}
+
+// newTokenSource returns the token reader shared by buildMap and Walk: the
+// next token of r, whether it came from a CDATA section (tracked when
+// tracker is non-nil) and the decoder error. In soup mode tokens are raw,
+// with their namespace prefixes resolved by rawNamespaces, and the caller
+// balances the tags.
+func newTokenSource(r io.Reader, cfg *config, tracker *cdataTracker) func() (xml.Token, bool, error) {
+	decoder := newTokenDecoder(r, cfg, tracker)
+	var scopes rawNamespaces
+
+	return func() (xml.Token, bool, error) {
+		offset := decoder.InputOffset()
+		var token xml.Token
+		var err error
+		if cfg.isSoupMode {
+			// Raw tokens: tags are balanced by buildMap (see
+			// EndElement), not by encoding/xml, which would drop
+			// content after a mismatched closing tag.
+			token, err = decoder.RawToken()
+		} else {
+			token, err = decoder.Token()
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if cfg.isSoupMode {
+			switch t := token.(type) {
+			case xml.StartElement:
+				token = scopes.start(t, containsFold(cfg.htmlAutoClose, t.Name.Local))
+			case xml.EndElement:
+				token = scopes.end(t)
+			}
+		}
+		return token, tracker != nil && tracker.isCDATA(offset, decoder.InputOffset()), nil
+	}
+}
+
+// rawNamespaces resolves the namespace prefixes of raw tokens (soup mode)
+// as encoding/xml's Token does, following the open elements the way
+// buildMap balances them: an end tag closes its nearest open namespace
+// scope of the same name, and one matching none is ignored.
+type rawNamespaces struct {
+	open []rawScope
+}
+
+type rawScope struct {
+	name xml.Name          // Raw (prefixed) name
+	ns   map[string]string // Prefix -> URI in scope ("" = default namespace)
+}
+
+// start translates an element's names, opening its scope unless void (an
+// element closed as soon as it opens).
+func (r *rawNamespaces) start(se xml.StartElement, void bool) xml.StartElement {
+	ns := r.current()
+	declared := false
+	for _, a := range se.Attr {
+		prefix, isDecl := "", a.Name.Space == "" && a.Name.Local == "xmlns"
+		if a.Name.Space == "xmlns" {
+			prefix, isDecl = a.Name.Local, true
+		}
+		if !isDecl {
+			continue
+		}
+		if !declared {
+			ns = maps.Clone(ns)
+			if ns == nil {
+				ns = make(map[string]string)
+			}
+			declared = true
+		}
+		ns[prefix] = a.Value
+	}
+	if !void {
+		r.open = append(r.open, rawScope{name: se.Name, ns: ns})
+	}
+
+	raw := se.Name
+	se.Name = translateRawName(raw, ns, true)
+	attrs := make([]xml.Attr, len(se.Attr))
+	for i, a := range se.Attr {
+		attrs[i] = xml.Attr{Name: translateRawName(a.Name, ns, false), Value: a.Value}
+	}
+	se.Attr = attrs
+	return se
+}
 
+// end translates an end tag with the scope of the element it closes.
+func (r *rawNamespaces) end(ee xml.EndElement) xml.EndElement {
+	for i := len(r.open) - 1; i >= 0; i-- {
+		if strings.EqualFold(r.open[i].name.Space, ee.Name.Space) && strings.EqualFold(r.open[i].name.Local, ee.Name.Local) {
+			ee.Name = translateRawName(ee.Name, r.open[i].ns, true)
+			r.open = r.open[:i]
+			return ee
+		}
+	}
+	ee.Name = translateRawName(ee.Name, r.current(), true)
+	return ee
+}
+
+func (r *rawNamespaces) current() map[string]string {
+	if len(r.open) == 0 {
+		return nil
+	}
+	return r.open[len(r.open)-1].ns
+}
+
+// translateRawName replaces the prefix of a raw name with its namespace
+// URI, with the rules of encoding/xml: xmlns declarations and unprefixed
+// attributes are left alone, "xml" is the XML namespace, unprefixed
+// elements take the default namespace and unknown prefixes are kept.
+func translateRawName(n xml.Name, ns map[string]string, isElement bool) xml.Name {
+	switch {
+	case n.Space == "xmlns",
+		n.Space == "" && !isElement,
+		n.Space == "" && n.Local == "xmlns":
+		return n
+	case n.Space == "xml":
+		n.Space = xmlNamespaceURL
+		return n
+	}
+	if uri, ok := ns[n.Space]; ok {
+		n.Space = uri
+	}
+	return n
+}
+
+// buildMap builds the document tree from the tokens returned by next (the
+// token and whether it was a CDATA section) until it returns io.EOF.
+func buildMap(next func() (xml.Token, bool, error), cfg *config) (*OrderedMap, error) {
 	root := NewMap()
-	stack := []*node{{tagName: "", data: root}}
+	rootNode := &node{tagName: "", data: root}
+	stack := []*node{rootNode}
+
+	// closeElement pops the innermost open element and attaches its
+	// (simplified) value to the parent.
+	closeElement := func() {
+		childNode := stack[len(stack)-1]
+		childNode.flushText(cfg)
+		stack = stack[:len(stack)-1]
+
+		if len(stack) == 0 {
+			return
+		}
+
+		parent := stack[len(stack)-1]
+		tagName := childNode.tagName
+
+		if childNode.hasComment {
+			childNode.data.Put("#seq", childNode.seq)
+		}
+
+		// Node Simplification
+		var finalValue any = childNode.data
+		if cfg.rawNodes {
+			finalValue = childNode.materialize()
+			if text, ok := childNode.data.Get("#text").(string); ok {
+				childNode.data.Put("#text", processValue(text, tagName, cfg))
+			}
+		} else if childNode.data == nil {
+			if childNode.text != "" {
+				finalValue = processValue(childNode.text, tagName, cfg)
+			} else {
+				finalValue = NewMap()
+			}
+		} else if isTextOnly(childNode.data, cfg) {
+			finalValue = processValue(childNode.data.Get("#text").(string), tagName, cfg)
+		}
+
+		if childKey, ok := cfg.collapseTo[tagName]; ok && !cfg.stringifyLeaves {
+			if node, isMap := finalValue.(*OrderedMap); isMap && node.Has(childKey) {
+				finalValue = node.Get(childKey)
+			}
+		}
+
+		// Add to Parent
+		existingValue := parent.data.Get(tagName)
+		if existingValue == nil {
+			if cfg.forceArrayKeys[tagName] {
+				parent.data.Put(tagName, []any{finalValue})
+			} else {
+				parent.data.Put(tagName, finalValue)
+			}
+		} else {
+			if list, ok := existingValue.([]any); ok {
+				parent.data.Put(tagName, append(list, finalValue))
+			} else {
+				parent.data.Put(tagName, []any{existingValue, finalValue})
+			}
+		}
+		if cfg.commentOrder {
+			parent.seq = append(parent.seq, seqEntry(tagName, finalValue))
+		}
+	}
 
 	for {
-		token, err := decoder.Token()
+		token, isCDATA, err := next()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			if cfg.isSoupMode {
-				continue
+				// Decoder errors are final: keep what was read so far.
+				break
 			}
 			return nil, wrapError(err)
 		}
@@ -133,6 +807,18 @@ func MapXML(r io.Reader, opts ...Option) (*OrderedMap, error) {
 				localName = strings.ToLower(localName)
 			}
 			tagName := resolveName(xml.Name{Space: se.Name.Space, Local: localName}, cfg.namespaces)
+			stack[len(stack)-1].flushText(cfg)
+			stack[len(stack)-1].materialize()
+
+			// Fast path: a bare element only needs a map if it turns out
+			// to have children, comments or PIs.
+			if len(se.Attr) == 0 && len(cfg.attrDefaults[tagName]) == 0 {
+				stack = append(stack, &node{tagName: tagName})
+				if cfg.isSoupMode && containsFold(cfg.htmlAutoClose, localName) {
+					closeElement()
+				}
+				continue
+			}
 
 			currentMap := NewMap()
 
@@ -143,66 +829,167 @@ func MapXML(r io.Reader, opts ...Option) (*OrderedMap, error) {
 					attrName = strings.ToLower(attrName)
 				}
 				attrName = resolveName(xml.Name{Space: attr.Name.Space, Local: attrName}, cfg.namespaces)
-				currentMap.Put("@"+attrName, processValue(attr.Value, "", cfg))
+				attrValue := normalizeAttrValue(attr.Value, cfg)
+				if sep, ok := cfg.splitAttrs[attrName]; ok && !cfg.stringifyLeaves {
+					currentMap.Put("@"+attrName, splitAttrValue(attrValue, sep, cfg))
+					continue
+				}
+				currentMap.Put("@"+attrName, processValue(attrValue, "", cfg))
+			}
+
+			// Inject missing default attributes (sorted for a deterministic order)
+			if defaults := cfg.attrDefaults[tagName]; len(defaults) > 0 {
+				names := make([]string, 0, len(defaults))
+				for k := range defaults {
+					names = append(names, k)
+				}
+				sort.Strings(names)
+				for _, k := range names {
+					if !currentMap.Has("@" + k) {
+						currentMap.Put("@"+k, processValue(defaults[k], "", cfg))
+					}
+				}
+			}
+
+			if cfg.attrGroupKey != "" {
+				currentMap = groupAttributes(currentMap, cfg.attrGroupKey)
 			}
 
 			stack = append(stack, &node{tagName: tagName, data: currentMap})
+			if cfg.isSoupMode && containsFold(cfg.htmlAutoClose, localName) {
+				closeElement()
+			}
 
 		case xml.CharData:
-			content := string(se)
-			trimmed := strings.TrimSpace(content)
+			stack[len(stack)-1].run.add(se, isCDATA)
 
-			// Only process significant content
-			if trimmed != "" {
+		case xml.Comment:
+			if cfg.commentOrder {
 				current := stack[len(stack)-1]
+				current.flushText(cfg)
+				current.materialize()
+				current.seq = append(current.seq, seqEntry("#comment", string(se)))
+				current.hasComment = true
+			}
 
-				// #text accumulation
-				if existingText := current.data.Get("#text"); existingText != nil {
-					current.data.Put("#text", existingText.(string)+trimmed)
-				} else {
-					current.data.Put("#text", trimmed)
+		case xml.ProcInst:
+			// Processing instructions inside the document body (the XML
+			// declaration and prolog PIs are not part of any element).
+			if cfg.commentOrder && len(stack) > 1 && se.Target != "xml" {
+				pi := se.Target
+				if inst := strings.TrimSpace(string(se.Inst)); inst != "" {
+					pi += " " + inst
 				}
+				current := stack[len(stack)-1]
+				current.flushText(cfg)
+				current.materialize()
+				current.seq = append(current.seq, seqEntry("#pi", pi))
+				current.hasComment = true
 			}
 
-		case xml.EndElement:
-			childNode := stack[len(stack)-1]
-			stack = stack[:len(stack)-1]
-
-			if len(stack) == 0 {
-				continue
+		case xml.Directive:
+			// Prolog directives (<!DOCTYPE ...>), outside any element.
+			if cfg.commentOrder && len(stack) == 1 {
+				root.Put("#directive", strings.TrimSpace(string(se)))
 			}
 
-			parent := stack[len(stack)-1]
-			tagName := childNode.tagName
-
-			// Node Simplification
-			var finalValue any = childNode.data
-			if childNode.data.Len() == 1 && childNode.data.Has("#text") {
-				finalValue = processValue(childNode.data.Get("#text").(string), tagName, cfg)
+		case xml.EndElement:
+			if !cfg.isSoupMode {
+				closeElement()
+				continue
 			}
-
-			// Add to Parent
-			existingValue := parent.data.Get(tagName)
-			if existingValue == nil {
-				if cfg.forceArrayKeys[tagName] {
-					parent.data.Put(tagName, []any{finalValue})
-				} else {
-					parent.data.Put(tagName, finalValue)
-				}
-			} else {
-				if list, ok := existingValue.([]any); ok {
-					parent.data.Put(tagName, append(list, finalValue))
-				} else {
-					parent.data.Put(tagName, []any{existingValue, finalValue})
+			// Tag balancing: a closing tag closes its nearest open
+			// element along with any still open inside it (<p><b>x</p>);
+			// one matching no open element (<b>x</i>) is ignored.
+			tagName := resolveName(xml.Name{Space: se.Name.Space, Local: strings.ToLower(se.Name.Local)}, cfg.namespaces)
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].tagName == tagName {
+					for len(stack) > i {
+						closeElement()
+					}
+					break
 				}
 			}
 		}
 	}
 
+	// Soup mode: close whatever the document left open.
+	for len(stack) > 1 {
+		closeElement()
+	}
+
+	rootNode.flushText(cfg)
+	if rootNode.hasComment {
+		root.Put("#seq", rootNode.seq)
+	}
+
 	return root, nil
 }
 
+// splitAttrValue splits a multi-valued attribute into tokens, dropping
+// empty ones.
+func splitAttrValue(val, sep string, cfg *config) []any {
+	var parts []string
+	if strings.TrimSpace(sep) == "" {
+		parts = strings.Fields(val)
+	} else {
+		parts = strings.Split(val, sep)
+	}
+	tokens := make([]any, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			tokens = append(tokens, processValue(p, "", cfg))
+		}
+	}
+	return tokens
+}
+
+// isTextOnly reports whether a node can be simplified to its "#text" value.
+// With SimplifyIgnoringAttrs, attribute keys (or the GroupAttributes key) do
+// not count against it.
+func isTextOnly(data *OrderedMap, cfg *config) bool {
+	if !data.Has("#text") {
+		return false
+	}
+	if !cfg.simplifyIgnoreAttrs {
+		return data.Len() == 1
+	}
+	for _, k := range data.Keys() {
+		if k != "#text" && !strings.HasPrefix(k, "@") && k != cfg.attrGroupKey {
+			return false
+		}
+	}
+	return true
+}
+
+// groupAttributes moves the "@" keys of an element into a single child map
+// under key, with the "@" prefix dropped.
+func groupAttributes(data *OrderedMap, key string) *OrderedMap {
+	attrs := NewMap()
+	rest := NewMap()
+	data.ForEach(func(k string, v any) bool {
+		if name, ok := strings.CutPrefix(k, "@"); ok {
+			attrs.Put(name, v)
+		} else {
+			rest.Put(k, v)
+		}
+		return true
+	})
+	if attrs.Len() == 0 {
+		return data
+	}
+	rest.Put(key, attrs)
+	return rest
+}
+
+// xmlNamespaceURL is the namespace bound to the reserved "xml" prefix
+// (xml:lang, xml:space...). It never needs a declaration.
+const xmlNamespaceURL = "http://www.w3.org/XML/1998/namespace"
+
 func resolveName(name xml.Name, nsMap map[string]string) string {
+	if name.Space == xmlNamespaceURL {
+		return "xml:" + name.Local
+	}
 	if alias, ok := nsMap[name.Space]; ok && alias != "" {
 		return alias + ":" + name.Local
 	}
@@ -210,20 +997,29 @@ func resolveName(name xml.Name, nsMap map[string]string) string {
 }
 
 func processValue(val string, tagName string, cfg *config) any {
+	if cfg.stringifyLeaves {
+		return val
+	}
 	if hook, ok := cfg.valueHooks[tagName]; ok {
 		return hook(val)
 	}
 	if cfg.inferTypes {
-		return inferType(val)
+		return inferType(val, cfg)
 	}
 	return val
 }
 
-func inferType(val string) any {
-	if val == "true" {
+func inferType(val string, cfg *config) any {
+	if cfg.boolTruthy != nil || cfg.boolFalsy != nil {
+		if containsFold(cfg.boolTruthy, val) {
+			return true
+		}
+		if containsFold(cfg.boolFalsy, val) {
+			return false
+		}
+	} else if val == "true" {
 		return true
-	}
-	if val == "false" {
+	} else if val == "false" {
 		return false
 	}
 	if i, err := strconv.Atoi(val); err == nil {
@@ -234,3 +1030,29 @@ func inferType(val string) any {
 	}
 	return val
 }
+
+var lineEndingReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+func normalizeLineEndings(s string) string {
+	return lineEndingReplacer.Replace(s)
+}
+
+var attrSpaceReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ", "\t", " ")
+
+// normalizeAttrValue implements NormalizeAttributeValues (XML 1.0 §3.3.3,
+// CDATA attributes).
+func normalizeAttrValue(s string, cfg *config) string {
+	if !cfg.normalizeAttrs || !strings.ContainsAny(s, "\t\n\r") {
+		return s
+	}
+	return attrSpaceReplacer.Replace(s)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestXPath_Lite(t *testing.T) {
-	data := map[string]any{
+func getXPathTestData() map[string]any {
+	return map[string]any{
 		"store": map[string]any{
 			"book": []any{
 				map[string]any{
@@ -50,6 +52,10 @@ func TestXPath_Lite(t *testing.T) {
 			},
 		},
 	}
+}
+
+func TestXPath_Lite(t *testing.T) {
+	data := getXPathTestData()
 
 	tests := []struct {
 		name     string
@@ -84,6 +90,26 @@ func TestXPath_Lite(t *testing.T) {
 			path:     "store/book[category!=fiction]/title",
 			expected: []any{"Sayings of the Century"},
 		},
+		{
+			name:     "Price != 8.95",
+			path:     "store/book[price!=8.95]/title",
+			expected: []any{"Sword of Honour", "Moby Dick", "The Lord of the Rings"},
+		},
+		{
+			name:     "Price != 8.950 (numeric)",
+			path:     "store/book[price!=8.950]/title",
+			expected: []any{"Sword of Honour", "Moby Dick", "The Lord of the Rings"},
+		},
+		{
+			name:     "Price = 8.950 (numeric)",
+			path:     "store/book[price=8.950]/title",
+			expected: []any{"Sayings of the Century"},
+		},
+		{
+			name:     "ISBN != empty (present)",
+			path:     "store/book[isbn!=]/title",
+			expected: []any{"Moby Dick", "The Lord of the Rings"},
+		},
 
 		// 3. Functions
 		{
@@ -108,6 +134,21 @@ func TestXPath_Lite(t *testing.T) {
 			path:     "//price",
 			expected: []any{8.95, 12.99, 8.99, 22.99, 19.95}, // books + bicycle
 		},
+		{
+			name:     "Deep search with filter and tail",
+			path:     "//book[price>20]/title",
+			expected: []any{"The Lord of the Rings"},
+		},
+		{
+			name:     "Deep search with function filter and tail",
+			path:     "//book[contains(author, 'Melville')]/isbn",
+			expected: []any{"0-553-21311-3"},
+		},
+		{
+			name:     "Deep search with plain tail",
+			path:     "//bicycle/color",
+			expected: []any{"red"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -139,3 +180,162 @@ func stringifyList(list []any) []string {
 	}
 	return strs
 }
+
+func TestXPath_Position(t *testing.T) {
+	data := getXPathTestData()
+
+	tests := []struct {
+		path     string
+		expected []any
+	}{
+		{"store/book[position() mod 2 = 0]/title", []any{"Sword of Honour", "The Lord of the Rings"}},
+		{"store/book[position() mod 2 = 1]/title", []any{"Sayings of the Century", "Moby Dick"}},
+		{"store/book[position() <= 2]/author", []any{"Nigel Rees", "Evelyn Waugh"}},
+		{"store/book[position() = 3]/title", []any{"Moby Dick"}},
+		{"store/bicycle[position() = 1]/color", []any{"red"}}, // single node = position 1
+		{"store/book[last()]/title", []any{"The Lord of the Rings"}},
+		{"store/book[last()-1]/title", []any{"Moby Dick"}},
+		{"store/book[position() > 2]/title", []any{"Moby Dick", "The Lord of the Rings"}},
+		{"store/book[position() < last() and category=fiction]/title", []any{"Sword of Honour", "Moby Dick"}},
+		{"store/book[last()-9]/title", nil},
+		{"store/bicycle[last()]/color", []any{"red"}},
+		{"//book[last()]/author", []any{"J. R. R. Tolkien"}},
+	}
+
+	for _, tt := range tests {
+		results, err := QueryAll(data, tt.path)
+		if err != nil {
+			t.Fatalf("QueryAll(%q) error: %v", tt.path, err)
+		}
+		// Document order is preserved (no sorting here)
+		if !reflect.DeepEqual(results, tt.expected) {
+			t.Errorf("QueryAll(%q) = %v; want %v", tt.path, results, tt.expected)
+		}
+	}
+}
+
+func TestXPath_CompoundFilters(t *testing.T) {
+	data := getXPathTestData()
+	users, _ := MapXML(strings.NewReader(`<users>
+		<user><name>Ana</name><email>ana@corp.com</email></user>
+		<user><name>Alberto</name><email>alberto@gmail.com</email></user>
+		<user><name>Bruno</name><email>bruno@corp.com</email></user>
+		<user><name>Andrea, and co</name><email>andrea@corp.com</email></user>
+	</users>`))
+	staff, _ := MapXML(strings.NewReader(`<staff>
+		<user><name>Ana</name><role>admin</role><active>true</active></user>
+		<user featured="true"><name>Tom and Jerry</name><role>admin</role><active>false</active></user>
+		<user><name>Rob or Bob</name><role>dev</role><active>false</active></user>
+	</staff>`))
+
+	tests := []struct {
+		data     any
+		path     string
+		expected []any
+	}{
+		{users, "users/user[starts-with(name,'A') and contains(email,'corp')]/name", []any{"Ana", "Andrea, and co"}},
+		{users, "users/user[contains(name,' and ') or name=Bruno]/name", []any{"Bruno", "Andrea, and co"}},
+		{data, "store/book[category=fiction and price<10]/title", []any{"Moby Dick"}},
+		{staff, "staff/user[role=admin and active=true]/name", []any{"Ana"}},
+		{staff, "staff/user[active=false or @featured=true]/name", []any{"Tom and Jerry", "Rob or Bob"}},
+		{staff, "staff/user[name='Tom and Jerry' or name=\"Rob or Bob\"]/role", []any{"admin", "dev"}},
+		{staff, "staff/user[role=admin]/name", []any{"Ana", "Tom and Jerry"}},
+		{data, "store/book[starts-with(author,'J') or price<9 and category=reference]/title", []any{"Sayings of the Century", "The Lord of the Rings"}},
+		{data, "store/book[position() > 1 and contains(title,'o')]/title", []any{"Sword of Honour", "Moby Dick", "The Lord of the Rings"}},
+	}
+
+	for _, tt := range tests {
+		results, err := QueryAll(tt.data, tt.path)
+		if err != nil {
+			t.Fatalf("QueryAll(%q) error: %v", tt.path, err)
+		}
+		if !reflect.DeepEqual(results, tt.expected) {
+			t.Errorf("QueryAll(%q) = %v; want %v", tt.path, results, tt.expected)
+		}
+	}
+}
+
+func TestXPath_DateFilters(t *testing.T) {
+	orders, _ := MapXML(strings.NewReader(`<orders>
+		<order id="1"><date>2024-12-31</date></order>
+		<order id="2"><date>2025-01-01</date></order>
+		<order id="3"><date>2025-03-15T10:30:00Z</date></order>
+		<order id="4"><date>pending</date></order>
+	</orders>`))
+
+	tests := []struct {
+		path string
+		want []any
+	}{
+		{"orders/order[date > '2025-01-01']/@id", []any{"3"}},
+		{"orders/order[date >= '2025-01-01']/@id", []any{"2", "3"}},
+		{"orders/order[date < '2025-01-01T00:00:00Z']/@id", []any{"1"}},
+		{"orders/order[date>'2024-06-01' and date<'2025-02-01']/@id", []any{"1", "2"}},
+	}
+	for _, tt := range tests {
+		got, err := QueryAll(orders, tt.path)
+		if err != nil {
+			t.Fatalf("QueryAll(%q) error: %v", tt.path, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryAll(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// time.Time values built in code compare the same way
+	data := map[string]any{"event": []any{
+		map[string]any{"name": "old", "at": time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		map[string]any{"name": "new", "at": time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}}
+	if got, _ := QueryAll(data, "event[at > '2025-01-01']/name"); !reflect.DeepEqual(got, []any{"new"}) {
+		t.Errorf("time.Time filter = %v", got)
+	}
+}
+
+func TestQueryGroupBy(t *testing.T) {
+	groups, err := QueryGroupBy(getXPathTestData(), "store/book", "category")
+	if err != nil {
+		t.Fatalf("QueryGroupBy error: %v", err)
+	}
+	if len(groups) != 2 || len(groups["fiction"]) != 3 || len(groups["reference"]) != 1 {
+		t.Fatalf("groups = %v", groups)
+	}
+	if title := groups["fiction"][0].(map[string]any)["title"]; title != "Sword of Honour" {
+		t.Errorf("first fiction book = %v, want document order", title)
+	}
+
+	// Grouping by a missing field puts everything under ""
+	groups, _ = QueryGroupBy(getXPathTestData(), "store/book", "isbn")
+	if len(groups[""]) != 2 || len(groups) != 3 {
+		t.Errorf("groups by isbn = %v", groups)
+	}
+}
+
+func TestExplainPath(t *testing.T) {
+	data := getXPathTestData()
+
+	out := ExplainPath(data, "store/book[price<10]/title")
+	for _, want := range []string{
+		"segment 'store': 1 in → 1 out",
+		"segment 'book[price<10]': 4 in → 2 out",
+		"segment 'title': 2 in → 2 out",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("ExplainPath missing %q. Got:\n%s", want, out)
+		}
+	}
+
+	out = ExplainPath(data, "//book[price>20]/title")
+	if !strings.Contains(out, "deep search 'book[price>20]': 1 out") || !strings.Contains(out, "segment 'title': 1 in → 1 out") {
+		t.Errorf("ExplainPath should report deep search and tail segments. Got:\n%s", out)
+	}
+
+	// A wrong key is reported where traversal stops
+	out = ExplainPath(data, "store/magazine/title")
+	if !strings.Contains(out, "segment 'magazine': 0 in → 0 out") || !strings.Contains(out, "stopped at segment 'magazine'") {
+		t.Errorf("ExplainPath should report the failing segment. Got:\n%s", out)
+	}
+	if strings.Contains(out, "'title'") {
+		t.Errorf("ExplainPath should stop after the failing segment. Got:\n%s", out)
+	}
+}
@@ -0,0 +1,328 @@
+package xml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// XSD SUBSET (ParseXSD / ValidateXSD)
+//
+// Scope: global and inline element declarations (name, type, ref,
+// minOccurs, maxOccurs), complexType with sequence/all/choice, attributes
+// (use="required") and simpleContent extensions, and simpleType
+// restrictions (enumeration, pattern, length, minLength, maxLength,
+// minInclusive, maxInclusive). Imports, includes, substitution groups,
+// keys and element ordering are NOT checked. The schema is turned into a
+// *Schema tree, so validation is ValidateSchema's.
+// ============================================================================
+
+// --- Raw XSD structs (unexported) -------------------------------------------
+
+type xsdSchema struct {
+	Elements     []xsdElement     `xml:"element"`
+	ComplexTypes []xsdComplexType `xml:"complexType"`
+	SimpleTypes  []xsdSimpleType  `xml:"simpleType"`
+}
+
+type xsdElement struct {
+	Name        string          `xml:"name,attr"`
+	Type        string          `xml:"type,attr"`
+	Ref         string          `xml:"ref,attr"`
+	MinOccurs   string          `xml:"minOccurs,attr"`
+	MaxOccurs   string          `xml:"maxOccurs,attr"`
+	ComplexType *xsdComplexType `xml:"complexType"`
+	SimpleType  *xsdSimpleType  `xml:"simpleType"`
+}
+
+type xsdGroup struct {
+	MinOccurs string       `xml:"minOccurs,attr"`
+	MaxOccurs string       `xml:"maxOccurs,attr"`
+	Elements  []xsdElement `xml:"element"`
+	Sequences []xsdGroup   `xml:"sequence"`
+	Choices   []xsdGroup   `xml:"choice"`
+}
+
+type xsdComplexType struct {
+	Name          string            `xml:"name,attr"`
+	Sequence      *xsdGroup         `xml:"sequence"`
+	All           *xsdGroup         `xml:"all"`
+	Choice        *xsdGroup         `xml:"choice"`
+	Attributes    []xsdAttribute    `xml:"attribute"`
+	SimpleContent *xsdSimpleContent `xml:"simpleContent"`
+}
+
+type xsdSimpleContent struct {
+	Extension struct {
+		Base       string         `xml:"base,attr"`
+		Attributes []xsdAttribute `xml:"attribute"`
+	} `xml:"extension"`
+}
+
+type xsdAttribute struct {
+	Name       string         `xml:"name,attr"`
+	Type       string         `xml:"type,attr"`
+	Use        string         `xml:"use,attr"`
+	SimpleType *xsdSimpleType `xml:"simpleType"`
+}
+
+type xsdSimpleType struct {
+	Name        string          `xml:"name,attr"`
+	Restriction *xsdRestriction `xml:"restriction"`
+}
+
+type xsdFacet struct {
+	Value string `xml:"value,attr"`
+}
+
+type xsdRestriction struct {
+	Base         string     `xml:"base,attr"`
+	Enumeration  []xsdFacet `xml:"enumeration"`
+	Pattern      []xsdFacet `xml:"pattern"`
+	Length       *xsdFacet  `xml:"length"`
+	MinLength    *xsdFacet  `xml:"minLength"`
+	MaxLength    *xsdFacet  `xml:"maxLength"`
+	MinInclusive *xsdFacet  `xml:"minInclusive"`
+	MaxInclusive *xsdFacet  `xml:"maxInclusive"`
+}
+
+// xsdMaxDepth stops the expansion of recursive types.
+const xsdMaxDepth = 32
+
+// ParseXSD reads the supported XSD subset and returns one Schema per global
+// element declaration, ready for ValidateSchema.
+func ParseXSD(r io.Reader) ([]*Schema, error) {
+	var raw xsdSchema
+	if err := xml.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid XSD: %w", wrapError(err))
+	}
+	b := &xsdBuilder{
+		elements:     make(map[string]*xsdElement),
+		complexTypes: make(map[string]*xsdComplexType),
+		simpleTypes:  make(map[string]*xsdSimpleType),
+	}
+	for i := range raw.Elements {
+		b.elements[raw.Elements[i].Name] = &raw.Elements[i]
+	}
+	for i := range raw.ComplexTypes {
+		b.complexTypes[raw.ComplexTypes[i].Name] = &raw.ComplexTypes[i]
+	}
+	for i := range raw.SimpleTypes {
+		b.simpleTypes[raw.SimpleTypes[i].Name] = &raw.SimpleTypes[i]
+	}
+
+	var schemas []*Schema
+	for _, e := range raw.Elements {
+		s := b.element(e, 0)
+		s.Required = true
+		s.Repeated = false
+		schemas = append(schemas, s)
+	}
+	return schemas, nil
+}
+
+// ValidateXSD validates doc (as returned by MapXML) against the XSD read
+// from xsd: its root element must be declared globally, and everything
+// under it is checked with ValidateSchema. Elements the schema does not
+// declare are ignored.
+func ValidateXSD(doc *OrderedMap, xsd io.Reader) []error {
+	schemas, err := ParseXSD(xsd)
+	if err != nil {
+		return []error{err}
+	}
+	for _, k := range doc.Keys() {
+		if strings.HasPrefix(k, "#") || strings.HasPrefix(k, "@") {
+			continue
+		}
+		for _, s := range schemas {
+			if s.Name == localName(k) {
+				root := *s
+				root.Name = k
+				return ValidateSchema(doc, &root)
+			}
+		}
+		return []error{fmt.Errorf("%s: root element is not declared in the schema", k)}
+	}
+	return []error{fmt.Errorf("document has no root element")}
+}
+
+type xsdBuilder struct {
+	elements     map[string]*xsdElement
+	complexTypes map[string]*xsdComplexType
+	simpleTypes  map[string]*xsdSimpleType
+}
+
+// element converts an element declaration, following ref and named types.
+func (b *xsdBuilder) element(e xsdElement, depth int) *Schema {
+	decl := e
+	if e.Ref != "" {
+		if global, ok := b.elements[localName(e.Ref)]; ok {
+			decl = *global
+		}
+	}
+	name := decl.Name
+	if name == "" {
+		name = localName(e.Ref)
+	}
+
+	s := &Schema{Name: name, Required: e.MinOccurs != "0"}
+	if minOccurs, err := strconv.Atoi(e.MinOccurs); err == nil && minOccurs > 1 {
+		s.MinOccurs = minOccurs
+	}
+	switch e.MaxOccurs {
+	case "", "1":
+	case "unbounded":
+		s.Repeated = true
+	default:
+		if maxOccurs, err := strconv.Atoi(e.MaxOccurs); err == nil && maxOccurs > 1 {
+			s.Repeated = true
+			s.MaxOccurs = maxOccurs
+		}
+	}
+	if depth > xsdMaxDepth {
+		return s
+	}
+
+	switch {
+	case decl.ComplexType != nil:
+		b.complexType(s, decl.ComplexType, depth)
+	case decl.SimpleType != nil:
+		b.simpleType(s, decl.SimpleType, depth)
+	case decl.Type != "":
+		b.namedType(s, decl.Type, depth)
+	}
+	return s
+}
+
+// namedType applies a type="..." reference: a schema type or a built-in.
+func (b *xsdBuilder) namedType(s *Schema, qname string, depth int) {
+	if ct, ok := b.complexTypes[localName(qname)]; ok {
+		b.complexType(s, ct, depth)
+		return
+	}
+	if st, ok := b.simpleTypes[localName(qname)]; ok {
+		b.simpleType(s, st, depth)
+		return
+	}
+	applyBuiltinType(s, localName(qname))
+}
+
+func (b *xsdBuilder) complexType(s *Schema, ct *xsdComplexType, depth int) {
+	for _, g := range []*xsdGroup{ct.Sequence, ct.All} {
+		if g != nil {
+			b.group(s, *g, false, depth)
+		}
+	}
+	if ct.Choice != nil {
+		b.group(s, *ct.Choice, true, depth)
+	}
+	attrs := append([]xsdAttribute{}, ct.Attributes...)
+	if sc := ct.SimpleContent; sc != nil {
+		b.namedType(s, sc.Extension.Base, depth)
+		attrs = append(attrs, sc.Extension.Attributes...)
+	}
+	for _, a := range attrs {
+		attr := &Schema{Name: "@" + a.Name, Required: a.Use == "required"}
+		if a.SimpleType != nil {
+			b.simpleType(attr, a.SimpleType, depth)
+		} else if a.Type != "" {
+			b.namedType(attr, a.Type, depth)
+		}
+		s.Children = append(s.Children, attr)
+	}
+}
+
+// group adds the elements of a sequence/all/choice to s. Choice members,
+// and members of optional groups, are never required on their own.
+func (b *xsdBuilder) group(s *Schema, g xsdGroup, choice bool, depth int) {
+	optional := choice || g.MinOccurs == "0"
+	repeated := g.MaxOccurs != "" && g.MaxOccurs != "1"
+	for _, e := range g.Elements {
+		child := b.element(e, depth+1)
+		if optional {
+			child.Required = false
+			child.MinOccurs = 0
+		}
+		if repeated {
+			child.Repeated = true
+			child.MaxOccurs = 0
+		}
+		s.Children = append(s.Children, child)
+	}
+	for _, seq := range g.Sequences {
+		b.group(s, seq, optional, depth)
+	}
+	for _, ch := range g.Choices {
+		b.group(s, ch, true, depth)
+	}
+}
+
+func (b *xsdBuilder) simpleType(s *Schema, st *xsdSimpleType, depth int) {
+	r := st.Restriction
+	if r == nil {
+		s.Type = "string"
+		return
+	}
+	if r.Base != "" && depth <= xsdMaxDepth {
+		b.namedType(s, r.Base, depth+1)
+	}
+	if s.Type == "" {
+		s.Type = "string"
+	}
+	if len(r.Enumeration) > 0 {
+		s.Enum = nil
+		for _, f := range r.Enumeration {
+			s.Enum = append(s.Enum, f.Value)
+		}
+	}
+	if len(r.Pattern) > 0 {
+		// XSD patterns are implicitly anchored; several are alternatives.
+		var alts []string
+		for _, f := range r.Pattern {
+			alts = append(alts, f.Value)
+		}
+		s.Regex = "^(?:" + strings.Join(alts, "|") + ")$"
+	}
+	if r.Length != nil {
+		s.MinLen, _ = strconv.Atoi(r.Length.Value)
+		s.MaxLen = s.MinLen
+	}
+	if r.MinLength != nil {
+		s.MinLen, _ = strconv.Atoi(r.MinLength.Value)
+	}
+	if r.MaxLength != nil {
+		s.MaxLen, _ = strconv.Atoi(r.MaxLength.Value)
+	}
+	if r.MinInclusive != nil {
+		s.Min, _ = strconv.ParseFloat(r.MinInclusive.Value, 64)
+		s.HasMin = true
+	}
+	if r.MaxInclusive != nil {
+		s.Max, _ = strconv.ParseFloat(r.MaxInclusive.Value, 64)
+		s.HasMax = true
+	}
+}
+
+// applyBuiltinType maps XSD built-in types onto Schema leaf constraints.
+func applyBuiltinType(s *Schema, name string) {
+	switch name {
+	case "int", "integer", "long", "short", "byte",
+		"nonNegativeInteger", "positiveInteger", "negativeInteger", "nonPositiveInteger",
+		"unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte":
+		s.Type = "int"
+	case "decimal", "float", "double":
+		s.Type = "float"
+	case "date":
+		s.Type = "string"
+		s.Format = "date"
+	case "boolean":
+		s.Type = "string"
+		s.Enum = []string{"true", "false", "1", "0"}
+	case "anyType":
+	default:
+		s.Type = "string"
+	}
+}
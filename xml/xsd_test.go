@@ -0,0 +1,174 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+)
+
+const invoiceXSD = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:element name="invoice">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="number" type="InvoiceNumber"/>
+				<xs:element name="issued" type="xs:date"/>
+				<xs:element name="currency" minOccurs="0">
+					<xs:simpleType>
+						<xs:restriction base="xs:string">
+							<xs:enumeration value="COP"/>
+							<xs:enumeration value="USD"/>
+						</xs:restriction>
+					</xs:simpleType>
+				</xs:element>
+				<xs:element ref="line" minOccurs="1" maxOccurs="3"/>
+				<xs:element name="total" type="Amount"/>
+			</xs:sequence>
+			<xs:attribute name="id" type="xs:int" use="required"/>
+		</xs:complexType>
+	</xs:element>
+
+	<xs:element name="line">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="qty" type="xs:int"/>
+				<xs:element name="note" type="xs:string" minOccurs="0"/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+
+	<xs:simpleType name="InvoiceNumber">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="SETT-\d+"/>
+			<xs:maxLength value="10"/>
+		</xs:restriction>
+	</xs:simpleType>
+
+	<xs:complexType name="Amount">
+		<xs:simpleContent>
+			<xs:extension base="xs:decimal">
+				<xs:attribute name="currencyID" use="required"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+</xs:schema>`
+
+func TestValidateXSD(t *testing.T) {
+	valid := `<invoice id="7">
+		<number>SETT-100</number>
+		<issued>2025-12-19</issued>
+		<currency>COP</currency>
+		<line><qty>2</qty></line>
+		<line><qty>1</qty><note>gift</note></line>
+		<total currencyID="COP">1190.00</total>
+	</invoice>`
+	m, _ := MapXML(strings.NewReader(valid))
+	if errs := ValidateXSD(m, strings.NewReader(invoiceXSD)); len(errs) != 0 {
+		t.Errorf("valid invoice reported errors: %v", errs)
+	}
+
+	invalid := `<invoice id="x">
+		<number>FACT-1</number>
+		<issued>19/12/2025</issued>
+		<currency>EUR</currency>
+		<line><qty>1</qty></line><line><qty>1</qty></line><line><qty>1</qty></line><line><qty>one</qty></line>
+		<total>10</total>
+	</invoice>`
+	m, _ = MapXML(strings.NewReader(invalid))
+	var got []string
+	for _, err := range ValidateXSD(m, strings.NewReader(invoiceXSD)) {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"invoice/number invalid format (Regex)",
+		"invoice/issued invalid format (date)",
+		"invoice/currency invalid value. Allowed: [COP USD]",
+		"invoice/line: occurs 4 times, want at most 3",
+		"invoice/line[3]/qty must be numeric",
+		"invoice/total/@currencyID: missing",
+		"invoice/@id must be numeric",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	m, _ = MapXML(strings.NewReader(`<receipt/>`))
+	if errs := ValidateXSD(m, strings.NewReader(invoiceXSD)); len(errs) != 1 || !strings.Contains(errs[0].Error(), "not declared") {
+		t.Errorf("undeclared root: %v", errs)
+	}
+}
+
+func TestParseXSD_Cardinality(t *testing.T) {
+	schemas, err := ParseXSD(strings.NewReader(invoiceXSD))
+	if err != nil {
+		t.Fatalf("ParseXSD failed: %v", err)
+	}
+	if len(schemas) != 2 || schemas[0].Name != "invoice" || schemas[1].Name != "line" {
+		t.Fatalf("global elements = %v", schemas)
+	}
+	var line, currency *Schema
+	for _, c := range schemas[0].Children {
+		switch c.Name {
+		case "line":
+			line = c
+		case "currency":
+			currency = c
+		}
+	}
+	if line == nil || !line.Required || !line.Repeated || line.MaxOccurs != 3 || len(line.Children) != 2 {
+		t.Errorf("line = %+v", line)
+	}
+	if currency == nil || currency.Required {
+		t.Errorf("currency should be optional: %+v", currency)
+	}
+}
+
+func TestValidateXSD_Facets(t *testing.T) {
+	xsd := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:element name="order">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="qty">
+					<xs:simpleType>
+						<xs:restriction base="xs:integer">
+							<xs:minInclusive value="0"/>
+						</xs:restriction>
+					</xs:simpleType>
+				</xs:element>
+				<xs:element name="code">
+					<xs:simpleType>
+						<xs:restriction base="xs:integer">
+							<xs:enumeration value="1"/>
+							<xs:enumeration value="2"/>
+						</xs:restriction>
+					</xs:simpleType>
+				</xs:element>
+				<xs:element name="ref">
+					<xs:simpleType>
+						<xs:restriction base="xs:string">
+							<xs:pattern value="["/>
+						</xs:restriction>
+					</xs:simpleType>
+				</xs:element>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`
+
+	m, _ := MapXML(strings.NewReader(`<order><qty>-5</qty><code>7</code><ref>A</ref></order>`))
+	var got []string
+	for _, err := range ValidateXSD(m, strings.NewReader(xsd)) {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"order/qty value -5.00 is less than minimum 0.00",
+		"order/code invalid value. Allowed: [1 2]",
+		"order/ref cannot be checked: invalid Regex",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("error %d = %q, want prefix %q", i, got[i], want[i])
+		}
+	}
+}